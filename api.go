@@ -1,19 +1,32 @@
 package csgo
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/m2q/siam-cs/model"
 	"io/ioutil"
 	"log"
+	"time"
 )
 
 // API is a provider of CSGO match data. It provides methods for fetching past and
 // future matches. Which matches are selected is up to the API implementation.
 type API interface {
 	// Fetch returns a list of past and future CSGO pro matches.
-	// The reference implementation is provided by HLTV
-	Fetch() (past, future []model.Match, err error)
+	// The reference implementation is provided by HLTV. Implementations must return
+	// once ctx is cancelled.
+	Fetch(ctx context.Context) (past, future []model.Match, err error)
+}
+
+// DiagnosticAPI is an API that additionally reports the rows it could not parse, so the
+// Oracle can decide whether a fetch is trustworthy, see SafetyGuard.MaxParseErrors.
+// HLTV implements DiagnosticAPI.
+type DiagnosticAPI interface {
+	API
+	// FetchWithDiagnostics is like Fetch, but additionally returns every row that was
+	// skipped because it could not be parsed.
+	FetchWithDiagnostics(ctx context.Context) (past, future []model.Match, diag []ParseError, err error)
 }
 
 // StubAPI is a stub that implements API. You can explicitly set the match data
@@ -24,6 +37,9 @@ type StubAPI struct {
 	Future    []model.Match
 	Logger    *log.Logger
 	LogActive bool
+	// Delay simulates the latency of a real API. Fetch returns early with an error if
+	// the context is cancelled during the delay.
+	Delay time.Duration
 }
 
 // SetMatches sets future and past matches to be returned by the API
@@ -34,10 +50,15 @@ func (s *StubAPI) SetMatches(past []model.Match, future []model.Match) {
 
 // Fetch returns a static list of past and future CSGO pro matches, which
 // can be set via SetMatches.
-func (s *StubAPI) Fetch() (past, future []model.Match, err error) {
+func (s *StubAPI) Fetch(ctx context.Context) (past, future []model.Match, err error) {
 	if s.LogActive {
 		s.Logger.Println("Stub API was fetched")
 	}
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case <-time.After(s.Delay):
+	}
 	past, err = s.getPastMatches()
 	if err != nil {
 		return nil, nil, err
@@ -68,11 +89,11 @@ func (s *StubAPI) getFutureMatches() ([]model.Match, error) {
 	return s.Future, nil
 }
 
-func CreateData() {
-	hltv := &HLTV{}
-	hltv.Fetch()
-	p, _ := hltv.getPastMatches()
-	f, _ := hltv.getFutureMatches()
+// CreateData fetches HLTV and writes the matches as reference data for the generator.
+// depth is the number of results pages to include, see HLTV.ResultsDepth.
+func CreateData(depth int) {
+	hltv := &HLTV{ResultsDepth: depth}
+	p, f, _ := hltv.Fetch(context.Background())
 	p = append(p, f...)
 	file, _ := json.MarshalIndent(p, "", "\t")
 	_ = ioutil.WriteFile("./generator/reference_data_x.json", file, 0644)
@@ -0,0 +1,342 @@
+// Package archive implements a persistent, file-backed store of every match version the
+// oracle has fetched, and every value it has published. It is the basis for audits,
+// backfills and answering what the oracle published at a given time.
+//
+// The archive is stored as an append-only file with one JSON record per line. Records
+// are never modified, so the file doubles as an audit log.
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// Kinds of records stored in the archive file
+const (
+	kindFetched   = "fetched"
+	kindPublished = "published"
+	kindRemoved   = "removed"
+)
+
+// record is a single line of the archive file.
+type record struct {
+	Kind  string       `json:"kind"`
+	Time  time.Time    `json:"time"`
+	ID    int          `json:"id"`
+	Match *model.Match `json:"match,omitempty"`
+	Key   string       `json:"key,omitempty"`
+	Value []byte       `json:"value"`
+}
+
+// Version is a version of a match, as fetched by the oracle.
+type Version struct {
+	Match     model.Match `json:"match"`
+	FetchedAt time.Time   `json:"fetched_at"`
+}
+
+// Publication is a value written to, or removed from, the buffer.
+type Publication struct {
+	// Match is the version of the match that was published
+	Match model.Match `json:"match"`
+	Key   string      `json:"key"`
+	Value []byte      `json:"value"`
+	Time  time.Time   `json:"time"`
+	// Removed is true if the key was deleted from the buffer
+	Removed bool `json:"removed"`
+}
+
+// Entry is the archived history of a single match.
+type Entry struct {
+	ID int `json:"id"`
+	// Versions contains every distinct version of the match, in the order they were fetched
+	Versions []Version `json:"versions"`
+	// Publications contains every change of the match on the buffer, in chronological order
+	Publications []Publication `json:"publications"`
+}
+
+// Latest returns the most recent version of the match.
+func (e Entry) Latest() model.Match {
+	if len(e.Versions) > 0 {
+		return e.Versions[len(e.Versions)-1].Match
+	}
+	if len(e.Publications) > 0 {
+		return e.Publications[len(e.Publications)-1].Match
+	}
+	return model.Match{ID: e.ID}
+}
+
+// VersionAt returns the most recent version of the match fetched at or before t.
+func (e Entry) VersionAt(t time.Time) (model.Match, bool) {
+	for i := len(e.Versions) - 1; i >= 0; i-- {
+		if !e.Versions[i].FetchedAt.After(t) {
+			return e.Versions[i].Match, true
+		}
+	}
+	return model.Match{}, false
+}
+
+// PublishedAt returns the publication that was on the buffer at time t. Returns false if
+// the match was not on the buffer at that time.
+func (e Entry) PublishedAt(t time.Time) (Publication, bool) {
+	for i := len(e.Publications) - 1; i >= 0; i-- {
+		if !e.Publications[i].Time.After(t) {
+			return e.Publications[i], !e.Publications[i].Removed
+		}
+	}
+	return Publication{}, false
+}
+
+// copy returns a deep copy of the entry, which is safe to hand out.
+func (e *Entry) copy() Entry {
+	c := Entry{ID: e.ID}
+	c.Versions = append(c.Versions, e.Versions...)
+	c.Publications = append(c.Publications, e.Publications...)
+	return c
+}
+
+// Archive is a file-backed store of matches, keyed by match ID. It is safe for concurrent
+// use.
+type Archive struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries map[int]*Entry
+}
+
+// Open opens the archive at path and loads all of its records. The file is created if it
+// does not exist. A partial record at the end of the file, which is left behind if the
+// process is interrupted while writing, is removed from the file.
+func Open(path string) (*Archive, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{file: f, entries: make(map[int]*Entry)}
+	if err := a.load(path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// load reads all records of the file. Records are terminated by a newline, so a final
+// line without one is the remainder of an interrupted write. It is truncated if it cannot
+// be parsed, and terminated otherwise.
+func (a *Archive) load(path string) error {
+	r := bufio.NewReader(a.file)
+	var offset int64
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(b) == 0 {
+			return nil
+		}
+		rec, parseErr := parseRecord(b)
+		if err == io.EOF {
+			if parseErr != nil {
+				return a.file.Truncate(offset)
+			}
+			a.apply(rec)
+			_, err := a.file.Write([]byte{'\n'})
+			return err
+		}
+		if parseErr != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, parseErr)
+		}
+		a.apply(rec)
+		offset += int64(len(b))
+	}
+}
+
+// parseRecord parses a single line of the archive file.
+func parseRecord(b []byte) (record, error) {
+	var r record
+	if err := json.Unmarshal(b, &r); err != nil {
+		return record{}, err
+	}
+	if r.Match == nil && r.Kind != kindRemoved {
+		return record{}, fmt.Errorf("%s record without match", r.Kind)
+	}
+	return r, nil
+}
+
+// Close closes the underlying file.
+func (a *Archive) Close() error {
+	return a.file.Close()
+}
+
+// apply adds the record to the in-memory index.
+func (a *Archive) apply(r record) {
+	e, ok := a.entries[r.ID]
+	if !ok {
+		e = &Entry{ID: r.ID}
+		a.entries[r.ID] = e
+	}
+	switch r.Kind {
+	case kindFetched:
+		e.Versions = append(e.Versions, Version{Match: *r.Match, FetchedAt: r.Time})
+	case kindPublished:
+		e.Publications = append(e.Publications, Publication{Match: *r.Match, Key: r.Key, Value: r.Value, Time: r.Time})
+	case kindRemoved:
+		p := Publication{Match: e.Latest(), Key: r.Key, Time: r.Time, Removed: true}
+		e.Publications = append(e.Publications, p)
+	}
+}
+
+// append writes the records to the file, and adds them to the index. Must be called
+// with the mutex held.
+func (a *Archive) append(records ...record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	var b []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		b = append(append(b, line...), '\n')
+	}
+	if _, err := a.file.Write(b); err != nil {
+		return err
+	}
+	for _, r := range records {
+		a.apply(r)
+	}
+	return nil
+}
+
+// RecordFetch stores every match whose version differs from the latest archived one.
+func (a *Archive) RecordFetch(t time.Time, matches ...model.Match) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	records := make([]record, 0)
+	for i := range matches {
+		m := matches[i]
+		if e, ok := a.entries[m.ID]; ok && len(e.Versions) > 0 && equal(e.Latest(), m) {
+			continue
+		}
+		records = append(records, record{Kind: kindFetched, Time: t, ID: m.ID, Match: &m})
+	}
+	return a.append(records...)
+}
+
+// RecordPublish stores that value was written to key, representing the match m.
+func (a *Archive) RecordPublish(t time.Time, m model.Match, key string, value []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.append(record{Kind: kindPublished, Time: t, ID: m.ID, Match: &m, Key: key, Value: value})
+}
+
+// RecordRemoval stores that the key of the match with the given ID was deleted from the
+// buffer.
+func (a *Archive) RecordRemoval(t time.Time, id int, key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.append(record{Kind: kindRemoved, Time: t, ID: id, Key: key})
+}
+
+// Len returns the number of archived matches.
+func (a *Archive) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.entries)
+}
+
+// Get returns the archived history of the match with the given ID.
+func (a *Archive) Get(id int) (Entry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e, ok := a.entries[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return e.copy(), true
+}
+
+// Select returns the entries whose latest version satisfies keep, ordered by match date.
+// A nil keep selects every entry.
+func (a *Archive) Select(keep func(model.Match) bool) []Entry {
+	a.mu.Lock()
+	selected := make([]Entry, 0)
+	for _, e := range a.entries {
+		if keep == nil || keep(e.Latest()) {
+			selected = append(selected, e.copy())
+		}
+	}
+	a.mu.Unlock()
+	sort.Slice(selected, func(i, j int) bool {
+		di, dj := selected[i].Latest().Date, selected[j].Latest().Date
+		if di.Equal(dj) {
+			return selected[i].ID < selected[j].ID
+		}
+		return di.Before(dj)
+	})
+	return selected
+}
+
+// ByTeam returns the entries of all matches played by the team with the given name. Names
+// are compared case-insensitively.
+func (a *Archive) ByTeam(name string) []Entry {
+	return a.Select(func(m model.Match) bool {
+		return strings.EqualFold(m.Team1.Name, name) || strings.EqualFold(m.Team2.Name, name)
+	})
+}
+
+// ByEvent returns the entries of all matches of the event with the given name. Names are
+// compared case-insensitively.
+func (a *Archive) ByEvent(name string) []Entry {
+	return a.Select(func(m model.Match) bool {
+		return strings.EqualFold(m.Event.Name, name)
+	})
+}
+
+// Between returns the entries of all matches dated within [from, to).
+func (a *Archive) Between(from, to time.Time) []Entry {
+	return a.Select(func(m model.Match) bool {
+		return !m.Date.Before(from) && m.Date.Before(to)
+	})
+}
+
+// StateAt returns the contents of the buffer at time t, as published by the oracle.
+func (a *Archive) StateAt(t time.Time) map[string][]byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state := make(map[string][]byte)
+	for _, e := range a.entries {
+		if p, ok := e.PublishedAt(t); ok {
+			state[p.Key] = p.Value
+		}
+	}
+	return state
+}
+
+// equal returns true if both matches are the same version. Dates are compared by instant,
+// since their location is lost when the archive is loaded, and empty map and ban slices
+// are equal.
+// Dates of live matches are ignored, since the API may report them with the time of the
+// fetch.
+func equal(a, b model.Match) bool {
+	if !a.Date.Equal(b.Date) && !(a.Live && b.Live) {
+		return false
+	}
+	a.Date, b.Date = time.Time{}, time.Time{}
+	if len(a.Maps) == 0 && len(b.Maps) == 0 {
+		a.Maps, b.Maps = nil, nil
+	}
+	if len(a.Bans) == 0 && len(b.Bans) == 0 {
+		a.Bans, b.Bans = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
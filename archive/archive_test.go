@@ -0,0 +1,126 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m2q/siam-cs/generator"
+	"github.com/m2q/siam-cs/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	t0 := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	past, future := generator.GetData(t0)
+	m := future[0]
+
+	a, err := Open(path)
+	assert.Nil(t, err)
+	assert.Nil(t, a.RecordFetch(t0, past...))
+	assert.Nil(t, a.RecordFetch(t0, future...))
+	// unchanged matches are not stored again
+	assert.Nil(t, a.RecordFetch(t0.Add(time.Minute), m))
+	assert.Nil(t, a.RecordPublish(t0.Add(time.Minute), m, "1", []byte("")))
+
+	m.Result = model.Result{Winner: m.Team1.Name, Score: "2-0"}
+	assert.Nil(t, a.RecordFetch(t0.Add(time.Hour), m))
+	assert.Nil(t, a.RecordPublish(t0.Add(time.Hour), m, "1", []byte(m.Team1.Name)))
+	assert.Nil(t, a.RecordRemoval(t0.Add(time.Hour*2), m.ID, "1"))
+	assert.Nil(t, a.Close())
+
+	a, err = Open(path)
+	assert.Nil(t, err)
+	defer a.Close()
+	assert.Equal(t, len(past)+len(future), a.Len())
+	assert.Nil(t, a.RecordFetch(t0.Add(time.Hour*3), m))
+
+	e, ok := a.Get(m.ID)
+	assert.True(t, ok)
+	assert.Len(t, e.Versions, 2)
+	assert.Len(t, e.Publications, 3)
+	assert.Equal(t, m.Result, e.Latest().Result)
+	v, ok := e.VersionAt(t0.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, "", v.Result.Winner)
+
+	// what did the oracle say at time T
+	p, ok := e.PublishedAt(t0.Add(time.Hour * 90 / 60))
+	assert.True(t, ok)
+	assert.Equal(t, m.Team1.Name, string(p.Value))
+	_, ok = e.PublishedAt(t0.Add(time.Hour * 2))
+	assert.False(t, ok)
+	assert.Equal(t, map[string][]byte{"1": []byte("")}, a.StateAt(t0.Add(time.Minute)))
+	assert.Empty(t, a.StateAt(t0))
+}
+
+// Tests if a record that was only partially written is dropped when the archive is opened
+func TestArchive_PartialRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	t0 := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	past, future := generator.GetData(t0)
+	a, err := Open(path)
+	assert.Nil(t, err)
+	assert.Nil(t, a.RecordFetch(t0, past...))
+	assert.Nil(t, a.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteString(`{"kind":"fetched","time":"2021-11-01T12:00:00Z","id":1,"ma`)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	a, err = Open(path)
+	assert.Nil(t, err)
+	assert.Equal(t, len(past), a.Len())
+	assert.Nil(t, a.RecordFetch(t0, future...))
+	assert.Nil(t, a.Close())
+
+	a, err = Open(path)
+	assert.Nil(t, err)
+	defer a.Close()
+	assert.Equal(t, len(past)+len(future), a.Len())
+}
+
+// Tests if live matches are not stored again when only their date changes
+func TestArchive_LiveDate(t *testing.T) {
+	t0 := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	_, future := generator.GetData(t0)
+	a, err := Open(filepath.Join(t.TempDir(), "archive.jsonl"))
+	assert.Nil(t, err)
+	defer a.Close()
+	m := future[0]
+	m.Live = true
+	for i := 0; i < 3; i++ {
+		m.Date = t0.Add(time.Minute * time.Duration(i))
+		assert.Nil(t, a.RecordFetch(m.Date, m))
+	}
+	e, _ := a.Get(m.ID)
+	assert.Len(t, e.Versions, 1)
+}
+
+func TestArchive_Queries(t *testing.T) {
+	t0 := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	past, future := generator.GetData(t0)
+	a, err := Open(filepath.Join(t.TempDir(), "archive.jsonl"))
+	assert.Nil(t, err)
+	defer a.Close()
+	assert.Nil(t, a.RecordFetch(t0, append(past, future...)...))
+
+	team := past[0].Team1.Name
+	for _, e := range a.ByTeam(team) {
+		m := e.Latest()
+		assert.True(t, m.Team1.Name == team || m.Team2.Name == team)
+	}
+	assert.NotEmpty(t, a.ByTeam(team))
+	assert.NotEmpty(t, a.ByEvent(past[0].Event.Name))
+	assert.Empty(t, a.ByEvent("unknown event"))
+
+	between := a.Between(t0, t0.Add(time.Hour*24*365))
+	assert.Len(t, between, len(future))
+	for i := 1; i < len(between); i++ {
+		assert.False(t, between[i].Latest().Date.Before(between[i-1].Latest().Date))
+	}
+}
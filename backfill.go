@@ -0,0 +1,46 @@
+package csgo
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/m2q/siam-cs/archive"
+	"github.com/m2q/siam-cs/model"
+)
+
+// Backfill walks the results of HLTV back in time, page by page, until it reaches results
+// dated before until, and stores every result dated at or after until in the archive.
+// Results that are already archived are not stored again. Pages are requested at most
+// every PageDelay of h. Returns the number of distinct results found up to until, which
+// is also valid if an error occurred.
+func Backfill(ctx context.Context, h *HLTV, a *archive.Archive, until time.Time) (int, error) {
+	seen := make(map[int]bool)
+	for offset := 0; ; offset += ResultsPerPage {
+		results, diag, err := h.FetchResults(ctx, offset)
+		for _, e := range diag {
+			log.Printf("skipped row: %v", e)
+		}
+		if err != nil {
+			return len(seen), err
+		}
+		if len(results) == 0 {
+			return len(seen), nil
+		}
+		archived := make([]model.Match, 0, len(results))
+		for _, m := range results {
+			if !m.Date.Before(until) {
+				archived = append(archived, m)
+				seen[m.ID] = true
+			}
+		}
+		if err := a.RecordFetch(time.Now(), archived...); err != nil {
+			return len(seen), err
+		}
+		log.Printf("backfilled %d results up to %s", len(seen), results[0].Date.Format("2006-01-02"))
+		// results are ordered from oldest to newest
+		if results[0].Date.Before(until) {
+			return len(seen), nil
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package csgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m2q/siam-cs/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveTestPages serves the saved pages in testdata like HLTV, and records the offsets of
+// all requested results pages.
+func serveTestPages(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	offsets := make([]string, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/matches":
+			http.ServeFile(w, r, filepath.Join("testdata", "matches.html"))
+		case "/results":
+			offset := r.URL.Query().Get("offset")
+			mu.Lock()
+			offsets = append(offsets, offset)
+			mu.Unlock()
+			if offset == "" {
+				http.ServeFile(w, r, filepath.Join("testdata", "results.html"))
+				return
+			}
+			http.ServeFile(w, r, filepath.Join("testdata", "results_offset_"+offset+".html"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, offsets...)
+	}
+}
+
+func TestHLTV_ResultsDepth(t *testing.T) {
+	srv, offsets := serveTestPages(t)
+	h := &HLTV{BaseURL: srv.URL, ResultsDepth: 5, PageDelay: time.Millisecond}
+	past, future, err := h.Fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, future, 3)
+
+	// duplicates are removed, oldest result first
+	ids := make([]int, 0)
+	for _, m := range past {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []int{2352650, 2352700, 2352763, 2352764, 2352765}, ids)
+	// the empty page ends the pagination
+	assert.Equal(t, []string{"", "100", "200"}, offsets())
+}
+
+func TestBackfill(t *testing.T) {
+	srv, offsets := serveTestPages(t)
+	a, err := archive.Open(filepath.Join(t.TempDir(), "archive.jsonl"))
+	assert.Nil(t, err)
+	defer a.Close()
+	h := &HLTV{BaseURL: srv.URL, PageDelay: time.Millisecond}
+
+	until := time.Unix(1636600000, 0)
+	n, err := Backfill(context.Background(), h, a, until)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 4, a.Len())
+	_, ok := a.Get(2352650)
+	assert.False(t, ok)
+	assert.Len(t, a.Between(until, time.Now()), 4)
+	// no further pages are requested once until is reached
+	assert.Equal(t, []string{"", "100"}, offsets())
+}
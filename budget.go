@@ -0,0 +1,103 @@
+package csgo
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// Size limits of a single key-value pair in the global state of an Algorand application.
+const (
+	// MaxKeyLength is the maximum length of a key in bytes
+	MaxKeyLength = 64
+	// MaxKeyValueLength is the maximum combined length of a key and its value in bytes
+	MaxKeyValueLength = 128
+)
+
+// EncodingIssue describes a match whose key-value pair exceeds the size limits of the
+// Algorand global state.
+type EncodingIssue struct {
+	MatchID int
+	Key     string
+	Value   string
+	// Truncated is true if the value was shortened to fit. Otherwise, the match was
+	// dropped from the state.
+	Truncated bool
+	Reason    string
+}
+
+func (e EncodingIssue) String() string {
+	action := "dropped"
+	if e.Truncated {
+		action = "truncated"
+	}
+	return fmt.Sprintf("match %d %s: %s", e.MatchID, action, e.Reason)
+}
+
+// FitValue shortens value deterministically, so that key and value together do not exceed
+// MaxKeyValueLength bytes. Values are cut at a UTF-8 character boundary. Returns false if
+// the key alone exceeds its limit, in which case the pair cannot be encoded at all.
+func FitValue(key, value string) (string, bool) {
+	if len(key) > MaxKeyLength {
+		return "", false
+	}
+	max := MaxKeyValueLength - len(key)
+	if len(value) <= max {
+		return value, true
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut], true
+}
+
+// encodeMatch returns the key-value pair of a match, fitted to the size limits. If the pair
+// had to be truncated or cannot be encoded at all, an EncodingIssue is returned. In the
+// latter case, ok is false.
+func encodeMatch(m model.Match, f Format) (key, value string, issue *EncodingIssue, ok bool) {
+	key = f.Key(m.ID)
+	if c, ok := f.encoding().(valueChecker); ok {
+		if err := c.CheckValue(m); err != nil {
+			return key, "", &EncodingIssue{MatchID: m.ID, Key: key, Reason: err.Error()}, false
+		}
+	}
+	value = f.encoding().EncodeValue(m)
+	fitted, ok := FitValue(key, value)
+	if !ok {
+		return key, "", &EncodingIssue{MatchID: m.ID, Key: key, Value: value,
+			Reason: fmt.Sprintf("key has %d bytes, limit is %d", len(key), MaxKeyLength)}, false
+	}
+	if fitted != value {
+		issue = &EncodingIssue{MatchID: m.ID, Key: key, Value: value, Truncated: true,
+			Reason: fmt.Sprintf("key and value have %d bytes, limit is %d", len(key)+len(value), MaxKeyValueLength)}
+	}
+	return key, fitted, issue, true
+}
+
+// encodedValue returns the value of a match as it is published, or an empty string if
+// the match cannot be encoded.
+func encodedValue(m model.Match, f Format) string {
+	_, value, _, _ := encodeMatch(m, f)
+	return value
+}
+
+// EncodeState converts matches into a state of the given Format. The encoded length of
+// every key-value pair is measured.
+// Values that are too long are truncated, see FitValue. Matches that cannot be encoded
+// are left out. Both cases are reported as EncodingIssue.
+func EncodeState(m []model.Match, f Format) (map[string]string, []EncodingIssue) {
+	state := make(map[string]string, len(m))
+	issues := make([]EncodingIssue, 0)
+	for _, v := range m {
+		key, value, issue, ok := encodeMatch(v, f)
+		if issue != nil {
+			issues = append(issues, *issue)
+		}
+		if ok {
+			state[key] = value
+		}
+	}
+	return state, issues
+}
@@ -0,0 +1,51 @@
+package csgo
+
+import (
+	"github.com/m2q/siam-cs/model"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestFitValue(t *testing.T) {
+	v, ok := FitValue("123", "G2")
+	assert.True(t, ok)
+	assert.Equal(t, "G2", v)
+
+	// multi-byte characters are not split
+	v, ok = FitValue(strings.Repeat("k", 64), strings.Repeat("ö", 40))
+	assert.True(t, ok)
+	assert.Equal(t, strings.Repeat("ö", 32), v)
+
+	_, ok = FitValue(strings.Repeat("k", 65), "")
+	assert.False(t, ok)
+}
+
+func TestEncodeState_ReportsTruncation(t *testing.T) {
+	long := strings.Repeat("Team ", 30)
+	state, issues := EncodeState([]model.Match{
+		{ID: 1, Result: model.Result{Winner: "G2"}},
+		{ID: 2, Result: model.Result{Winner: long}},
+	}, Format{})
+	assert.Equal(t, "G2", state["1"])
+	assert.Len(t, state["2"], MaxKeyValueLength-1)
+	assert.Len(t, issues, 1)
+	assert.Equal(t, 2, issues[0].MatchID)
+	assert.True(t, issues[0].Truncated)
+}
+
+func TestEncodeState_ReportsUnknownWinner(t *testing.T) {
+	teams := func(m model.Match) model.Match {
+		m.Team1, m.Team2 = model.Team{Name: "G2", ID: 5995}, model.Team{Name: "NIP", ID: 4411}
+		return m
+	}
+	state, issues := EncodeState([]model.Match{
+		teams(model.Match{ID: 1, Result: model.Result{Winner: "G2", Score: "2-0"}}),
+		teams(model.Match{ID: 2, Result: model.Result{Winner: "Ninjas in Pyjamas", Score: "2-0"}}),
+	}, Format{Encoding: CompactEncoding{}})
+	assert.Contains(t, state, "1")
+	assert.NotContains(t, state, "2")
+	assert.Len(t, issues, 1)
+	assert.Equal(t, 2, issues[0].MatchID)
+	assert.False(t, issues[0].Truncated)
+}
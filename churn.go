@@ -0,0 +1,125 @@
+package csgo
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// Priorities of buffer changes. Lower values are applied first.
+const (
+	// priorityResult is the priority of publishing the winner of a concluded match
+	priorityResult = iota
+	// priorityUpdate is the priority of any other addition or update, e.g. a newly
+	// announced match
+	priorityUpdate
+)
+
+// put is a single addition or update of a buffer key.
+type put struct {
+	key      string
+	value    string
+	priority int
+	match    model.Match
+}
+
+// IndexMatches returns a map from buffer key to match, where keys are given by Format f.
+func IndexMatches(f Format, m ...[]model.Match) map[string]model.Match {
+	index := make(map[string]model.Match)
+	for _, matches := range m {
+		for _, v := range matches {
+			index[f.Key(v.ID)] = v
+		}
+	}
+	return index
+}
+
+// LimitChurn returns a state between current and desired, which differs from current by
+// at most max added, changed or deleted keys. The buffer holds at most l keys. Changes
+// are applied in the following order, until max is reached:
+//
+//  1. Winners of concluded matches, most recent match first
+//  2. Other additions and updates (e.g. newly announced matches), earliest match first
+//  3. Deletions (e.g. matches older than PastMatchesTTL), oldest match ID first
+//
+// If an added key does not fit into the buffer, a deletion is pulled forward to make room.
+// The matches index is used to look up match dates, see IndexMatches.
+func LimitChurn(current, desired map[string]string, matches map[string]model.Match, max, l int) map[string]string {
+	puts := make([]put, 0)
+	for k, v := range desired {
+		if old, ok := current[k]; ok && old == v {
+			continue
+		}
+		p := put{key: k, value: v, priority: priorityUpdate, match: matches[k]}
+		if p.match.Result.Winner != "" {
+			p.priority = priorityResult
+		}
+		puts = append(puts, p)
+	}
+	sort.Slice(puts, func(i, j int) bool {
+		a, b := puts[i], puts[j]
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		if !a.match.Date.Equal(b.match.Date) {
+			if a.priority == priorityResult {
+				return a.match.Date.After(b.match.Date)
+			}
+			return a.match.Date.Before(b.match.Date)
+		}
+		return a.key < b.key
+	})
+
+	dels := make([]string, 0)
+	for k := range current {
+		if _, ok := desired[k]; !ok {
+			dels = append(dels, k)
+		}
+	}
+	sort.Slice(dels, func(i, j int) bool { return keyLess(dels[i], dels[j]) })
+
+	state := make(map[string]string, len(current))
+	for k, v := range current {
+		state[k] = v
+	}
+	budget, d := max, 0
+	for _, p := range puts {
+		if budget == 0 {
+			break
+		}
+		if _, ok := state[p.key]; !ok && len(state) >= l {
+			// make room for the new key, if possible
+			if d == len(dels) || budget < 2 {
+				continue
+			}
+			delete(state, dels[d])
+			d++
+			budget--
+		}
+		state[p.key] = p.value
+		budget--
+	}
+	for ; d < len(dels) && budget > 0; d++ {
+		delete(state, dels[d])
+		budget--
+	}
+	return state
+}
+
+// keyLess orders buffer keys by numeric match ID, which is the part of the key after the
+// last colon. Keys without a match ID are ordered after all others, lexicographically.
+func keyLess(a, b string) bool {
+	x, errA := strconv.Atoi(a[strings.LastIndex(a, ":")+1:])
+	y, errB := strconv.Atoi(b[strings.LastIndex(b, ":")+1:])
+	switch {
+	case errA == nil && errB == nil:
+		return x < y
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	}
+	return a < b
+}
@@ -0,0 +1,82 @@
+package csgo
+
+import (
+	"context"
+	"github.com/m2q/algo-siam/client"
+	"github.com/m2q/siam-cs/codec"
+	"github.com/m2q/siam-cs/generator"
+	"github.com/m2q/siam-cs/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLimitChurn_Priority(t *testing.T) {
+	now := time.Now()
+	matches := IndexMatches(Format{}, []model.Match{
+		{ID: 1, Date: now.Add(-time.Hour * 5)},
+		{ID: 2, Date: now.Add(-time.Hour * 2), Result: model.Result{Winner: "B"}},
+		{ID: 3, Date: now.Add(-time.Hour), Result: model.Result{Winner: "C"}},
+		{ID: 4, Date: now.Add(time.Hour * 2)},
+		{ID: 5, Date: now.Add(time.Hour)},
+	})
+	current := map[string]string{"1": "A", "2": "", "3": ""}
+	desired := map[string]string{"2": "B", "3": "C", "4": "", "5": ""}
+
+	// the most recent result is published first
+	assert.Equal(t, map[string]string{"1": "A", "2": "", "3": "C"},
+		LimitChurn(current, desired, matches, 1, 64))
+	// then remaining results, then upcoming matches in chronological order
+	assert.Equal(t, map[string]string{"1": "A", "2": "B", "3": "C", "5": ""},
+		LimitChurn(current, desired, matches, 3, 64))
+	// deletions come last
+	assert.Equal(t, desired, LimitChurn(current, desired, matches, 5, 64))
+}
+
+func TestLimitChurn_MakeRoom(t *testing.T) {
+	current := map[string]string{"1": "A", "2": "B"}
+	desired := map[string]string{"2": "B", "3": ""}
+	// buffer is full, so adding "3" requires deleting "1" first
+	assert.Equal(t, map[string]string{"2": "B"}, LimitChurn(current, desired, IndexMatches(Format{}), 1, 2))
+	assert.Equal(t, desired, LimitChurn(current, desired, IndexMatches(Format{}), 2, 2))
+}
+
+// Tests if the Oracle converges to the desired state when churn is limited
+func TestOracle_MaxChurn(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	oracle, b, stub := setupOracleMockedAPI(0)
+	oracle.cfg.MaxChurn = 10
+	stub.SetMatches(past, future)
+	oracle.Serve()
+	defer oracle.Stop()
+	assert.True(t, containsDesiredState(b, past, future, time.Second*2))
+}
+
+// Tests if the schema metadata and the history root neither count against MaxChurn, nor
+// overfill the buffer
+func TestOracle_MaxChurnReservedKeys(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	p := NewMemoryPublisher()
+	oracle := NewOracle(p, &OracleConfig{
+		PrimaryAPI:    stub,
+		KeySchema:     codec.KeySchema{Version: 1, Source: "hltv", Game: "csgo"},
+		CommitHistory: true,
+		Archive:       tempArchive(t),
+		MaxChurn:      10,
+	})
+	f := oracle.format()
+	for i := 1; i <= 8; i++ {
+		oracle.RunOnce(context.Background())
+		state, _ := p.GetBuffer(context.Background())
+		assert.LessOrEqual(t, len(state), client.GlobalBytes)
+		n := i * 10
+		if n > client.GlobalBytes-oracle.reserved() {
+			n = client.GlobalBytes - oracle.reserved()
+		}
+		assert.Len(t, f.matchEntries(state), n)
+		assert.Contains(t, state, oracle.rootKey())
+		assert.Contains(t, state, f.Keys.Reserved(codec.MetaKey))
+	}
+}
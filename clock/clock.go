@@ -0,0 +1,84 @@
+// Package clock provides an abstraction of time, so that time-dependent behavior of
+// the oracle (e.g. PastMatchesTTL) can be simulated quickly and deterministically.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the
+	// returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the Clock of the system, as provided by the time package.
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// waiter is a pending channel of Manual.After
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// Manual is a Clock that only advances when Advance or Set is called. It is safe for
+// concurrent use.
+type Manual struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+// NewManual returns a Manual clock, set to the given time.
+func NewManual(t time.Time) *Manual {
+	return &Manual{now: t}
+}
+
+// Now returns the current time of the clock.
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// After returns a channel that receives the current time, once the clock has been
+// advanced by at least d.
+func (m *Manual) After(d time.Duration) <-chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- m.now
+		return ch
+	}
+	m.waiters = append(m.waiters, waiter{deadline: m.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, and fires all timers that have expired.
+func (m *Manual) Advance(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Set sets the clock to t, and fires all timers that have expired.
+func (m *Manual) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = t
+	pending := m.waiters[:0]
+	for _, w := range m.waiters {
+		if w.deadline.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- t
+	}
+	m.waiters = pending
+}
@@ -0,0 +1,60 @@
+// Command backfill walks the results of HLTV back to a given date, and stores them in the
+// archive of the oracle (see package archive).
+//
+//	backfill -archive archive.jsonl -until 2021-01-01
+//
+// Results pages are requested at a polite rate, see -delay. Pages are addressed by offset
+// from the most recent result, so running the command again requests every page again,
+// but results that are already archived are not stored again. Archived results fill the
+// slots of the oracle with -fill-from-archive.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/m2q/siam-cs"
+	"github.com/m2q/siam-cs/archive"
+)
+
+func main() {
+	archivePath := flag.String("archive", "", "file path of the archive")
+	until := flag.String("until", "", "date (YYYY-MM-DD) of the oldest result to backfill")
+	delay := flag.Duration("delay", csgo.DefaultPageDelay, "pause between two requests of results pages")
+	selectors := flag.String("selectors", "", "file path of a JSON config of HLTV selectors")
+	flag.Parse()
+
+	if *archivePath == "" || *until == "" {
+		log.Fatal("both -archive and -until are required")
+	}
+	date, err := time.Parse("2006-01-02", *until)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hltv := &csgo.HLTV{PageDelay: *delay}
+	if *selectors != "" {
+		hltv.Selectors, err = csgo.LoadSelectors(*selectors)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	a, err := archive.Open(*archivePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer a.Close()
+
+	// stop gracefully on interrupt, archived results are kept
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	n, err := csgo.Backfill(ctx, hltv, a, date)
+	log.Printf("backfilled %d results since %s", n, date.Format("2006-01-02"))
+	if err != nil {
+		a.Close()
+		log.Fatal(err)
+	}
+}
@@ -1,33 +1,133 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
 	"time"
 
 	siam "github.com/m2q/algo-siam"
 	"github.com/m2q/siam-cs"
+	"github.com/m2q/siam-cs/archive"
+	"github.com/m2q/siam-cs/codec"
 )
 
 func main() {
+	// errors are returned by run, so its deferred calls, e.g. closing the archive, are
+	// executed before exiting
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	dryRun := flag.Bool("dry-run", false, "print the changes of a single cycle instead of publishing them")
+	watchlist := flag.String("watchlist", "", "file path or URL of a JSON watchlist of pinned matches")
+	compact := flag.Bool("compact", false, "publish values in the compact binary encoding instead of winner names")
+	schema := flag.Int("schema", codec.LegacyVersion, "key schema version, 0 publishes bare match IDs as keys")
+	listen := flag.String("listen", "", "address to serve inclusion proofs of published results on, enables history commitment (requires -archive)")
+	archivePath := flag.String("archive", "", "file path of the archive that stores every fetched and published match")
+	fill := flag.Bool("fill-from-archive", false, "fill slots that fetched matches leave empty with older archived results (requires -archive)")
+	refuseForeign := flag.Bool("refuse-foreign", false, "refuse to start if the buffer contains keys the oracle did not write")
+	selectors := flag.String("selectors", "", "file path of a JSON config of HLTV selectors, see cmd/validate-selectors")
+	resultsDepth := flag.Int("results-depth", 1, "number of HLTV results pages to fetch, with 100 results each")
+	maps := flag.Bool("maps", false, "fetch the maps of every match that enters the buffer from its HLTV match page")
+	flag.Parse()
 
 	// Create AlgorandBuffer
 	b, err := siam.NewAlgorandBufferFromEnv()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	// Configure HLTV scraper
+	hltv := &csgo.HLTV{ResultsDepth: *resultsDepth}
+	if *selectors != "" {
+		hltv.Selectors, err = csgo.LoadSelectors(*selectors)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Configure Oracle
 	cfg := &csgo.OracleConfig{
-		PrimaryAPI:      &csgo.HLTV{},
-		RefreshInterval: time.Minute * 3,
+		PrimaryAPI:        hltv,
+		RefreshInterval:   time.Minute * 3,
+		DryRun:            *dryRun,
+		RefuseForeignData: *refuseForeign,
+	}
+	if *schema != codec.LegacyVersion {
+		cfg.KeySchema = codec.KeySchema{Version: *schema, Source: "hltv", Game: "csgo"}
+	}
+	if *listen != "" {
+		if *archivePath == "" {
+			return errors.New("-listen requires -archive, from which the history is restored after a restart")
+		}
+		cfg.CommitHistory = true
+	}
+	if *maps {
+		cfg.Details = csgo.NewDetailFetcher(hltv, csgo.DefaultDetailInterval)
+	}
+	if *compact {
+		cfg.Encoding = csgo.CompactEncoding{}
+	}
+
+	if *fill {
+		if *archivePath == "" {
+			return errors.New("-fill-from-archive requires -archive")
+		}
+		cfg.FillFromArchive = true
+	}
+	if *archivePath != "" {
+		cfg.Archive, err = archive.Open(*archivePath)
+		if err != nil {
+			return err
+		}
+		defer cfg.Archive.Close()
+	}
+
+	if *watchlist != "" {
+		cfg.Watchlist, err = csgo.LoadWatchlist(context.Background(), *watchlist)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Create Oracle
 	oracle := csgo.NewOracle(b, cfg)
 
+	// In dry-run mode, only inspect a single cycle
+	if *dryRun {
+		oracle.RunOnce(context.Background())
+		return nil
+	}
+
+	// Serve inclusion proofs
+	serveErr := make(chan error, 1)
+	if *listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle(csgo.ProofPath, oracle.ProofHandler())
+		go func() {
+			serveErr <- http.ListenAndServe(*listen, mux)
+		}()
+	}
+
 	// Start Oracle
 	oracle.Serve()
 
-	// Wait until oracle finishes
-	oracle.Wait()
+	// Wait until oracle finishes, or the proof server fails
+	done := make(chan struct{})
+	go func() {
+		oracle.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return oracle.Err()
+	case err := <-serveErr:
+		oracle.Stop()
+		return err
+	}
 }
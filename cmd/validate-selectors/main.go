@@ -0,0 +1,111 @@
+// Command validate-selectors checks a HLTV selector configuration against saved pages,
+// before it is deployed to the oracle. It prints every parsed match, and exits with a
+// non-zero status if a page fails the layout checks of the oracle (see
+// csgo.LayoutChangedError), contains rows that cannot be parsed (see csgo.ParseError),
+// yields no matches, or matches with missing fields.
+//
+//	validate-selectors -selectors selectors.json -matches matches.html -results results.html
+//
+// With -print-defaults, the compiled-in selectors are printed, as a starting point for a
+// config file.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/m2q/siam-cs"
+	"github.com/m2q/siam-cs/model"
+)
+
+func main() {
+	selectors := flag.String("selectors", "", "selector config file, the compiled-in defaults are used if empty")
+	matches := flag.String("matches", "", "saved HTML of https://www.hltv.org/matches")
+	results := flag.String("results", "", "saved HTML of https://www.hltv.org/results")
+	printDefaults := flag.Bool("print-defaults", false, "print the compiled-in selectors and exit")
+	flag.Parse()
+
+	if *printDefaults {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(csgo.DefaultSelectors()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *matches == "" || *results == "" {
+		log.Fatal("both -matches and -results are required")
+	}
+
+	h := &csgo.HLTV{}
+	if *selectors != "" {
+		s, err := csgo.LoadSelectors(*selectors)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h.Selectors = s
+	}
+	if err := h.ReadPages(*matches, *results); err != nil {
+		log.Fatal(err)
+	}
+	past, future, diag, err := h.ParseWithDiagnostics()
+	if _, changed := err.(*csgo.LayoutChangedError); changed {
+		// print the matches anyway, to help fixing the selectors
+		log.Print(err)
+		h.Layout.Disabled = true
+		past, future, diag, err = h.ParseWithDiagnostics()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ok := check("results", past, true)
+	ok = check("matches", future, false) && ok
+	for _, e := range diag {
+		fmt.Printf("skipped: %v\n", e)
+	}
+	if !ok || len(diag) > 0 || h.Layout.Disabled {
+		os.Exit(1)
+	}
+}
+
+// check prints the matches parsed from a page, and returns false if there are none, or
+// some of them are missing fields.
+func check(page string, matches []model.Match, finished bool) bool {
+	fmt.Printf("%s: %d matches\n", page, len(matches))
+	ok := len(matches) > 0
+	for _, m := range matches {
+		problems := missing(m, finished)
+		status := "ok"
+		if len(problems) > 0 {
+			status = fmt.Sprintf("missing %v", problems)
+			ok = false
+		}
+		fmt.Printf("  %d %s vs %s (%s) %s: %s\n", m.ID, m.Team1.Name, m.Team2.Name, m.Event.Name, m.Date.UTC().Format("2006-01-02 15:04"), status)
+	}
+	return ok
+}
+
+// missing returns the names of required fields that were not parsed.
+func missing(m model.Match, finished bool) []string {
+	fields := make([]string, 0)
+	if m.ID == 0 {
+		fields = append(fields, "id")
+	}
+	if m.Team1.Name == "" || m.Team2.Name == "" {
+		fields = append(fields, "teams")
+	}
+	if m.Event.Name == "" {
+		fields = append(fields, "event")
+	}
+	if m.Date.IsZero() {
+		fields = append(fields, "date")
+	}
+	if finished && m.Result.Winner == "" {
+		fields = append(fields, "winner")
+	}
+	return fields
+}
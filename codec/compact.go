@@ -0,0 +1,199 @@
+// Package codec contains the encodings of values that the oracle publishes on-chain.
+// It has no dependencies on the oracle itself, so consumers can decode published data
+// without pulling in the oracle.
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// CompactVersion1 is the first version of the compact encoding. Layout (22 bytes, all
+// integers big-endian):
+//
+//	[0]      version (1)
+//	[1]      status flags, see Flags
+//	[2]      winner side, see Side
+//	[3]      score of the winner, e.g. maps won
+//	[4]      score of the loser
+//	[5]      best-of format, e.g. 3 for a best-of-three. Zero if unknown.
+//	[6:10]   team 1 ID
+//	[10:14]  team 2 ID
+//	[14:22]  scheduled time of the match as unix timestamp (seconds). This is not the
+//	         time the match ended, which the source does not report.
+const CompactVersion1 = 1
+
+// CompactLength is the length of a compact value of version 1 in bytes.
+const CompactLength = 22
+
+// Side identifies a team of a match.
+type Side uint8
+
+const (
+	// SideNone means there is no winner (yet)
+	SideNone Side = iota
+	SideTeam1
+	SideTeam2
+)
+
+// Flags describe the status of a match.
+type Flags uint8
+
+const (
+	// FlagLive is set if the match is currently being played
+	FlagLive Flags = 1 << iota
+	// FlagFinished is set if the match has concluded
+	FlagFinished
+)
+
+// Compact is the decoded form of a compact value.
+type Compact struct {
+	Version     uint8
+	Flags       Flags
+	Winner      Side
+	WinnerScore uint8
+	LoserScore  uint8
+	BestOf      uint8
+	Team1ID     uint32
+	Team2ID     uint32
+	// Time is the scheduled time of the match
+	Time time.Time
+}
+
+// ErrUnknownWinner is returned by CheckMatch if the winner of a match is neither of its
+// teams, e.g. because a team was renamed.
+var ErrUnknownWinner = errors.New("winner is neither team of the match")
+
+// CheckMatch returns an error if m cannot be represented in the compact form. This is the
+// case if the match has a winner, whose side cannot be determined, see FromMatch.
+func CheckMatch(m model.Match) error {
+	if w := m.Result.Winner; w != "" && w != m.Team1.Name && w != m.Team2.Name {
+		return fmt.Errorf("%w: %q in %q vs %q", ErrUnknownWinner, w, m.Team1.Name, m.Team2.Name)
+	}
+	return nil
+}
+
+// FromMatch converts a match into its compact form. The winner side is determined by
+// comparing Result.Winner with the names of both teams. If neither team matches, the side
+// is SideNone, so matches should be checked with CheckMatch first.
+func FromMatch(m model.Match) Compact {
+	c := Compact{
+		Version: CompactVersion1,
+		Team1ID: uint32(m.Team1.ID),
+		Team2ID: uint32(m.Team2.ID),
+		BestOf:  parseBestOf(m.Format),
+		Time:    m.Date,
+	}
+	if m.Live {
+		c.Flags |= FlagLive
+	}
+	if m.Result.Winner != "" {
+		c.Flags |= FlagFinished
+		switch m.Result.Winner {
+		case m.Team1.Name:
+			c.Winner = SideTeam1
+		case m.Team2.Name:
+			c.Winner = SideTeam2
+		}
+		c.WinnerScore, c.LoserScore = parseScore(m.Result.Score)
+	}
+	return c
+}
+
+// Encode returns the binary representation of c.
+func (c Compact) Encode() []byte {
+	b := make([]byte, CompactLength)
+	b[0] = CompactVersion1
+	b[1] = byte(c.Flags)
+	b[2] = byte(c.Winner)
+	b[3] = c.WinnerScore
+	b[4] = c.LoserScore
+	b[5] = c.BestOf
+	binary.BigEndian.PutUint32(b[6:10], c.Team1ID)
+	binary.BigEndian.PutUint32(b[10:14], c.Team2ID)
+	var ts int64
+	if !c.Time.IsZero() {
+		ts = c.Time.Unix()
+	}
+	binary.BigEndian.PutUint64(b[14:22], uint64(ts))
+	return b
+}
+
+// DecodeCompact parses a compact value. Returns an error if the version is unknown or
+// the value is malformed.
+func DecodeCompact(b []byte) (Compact, error) {
+	if len(b) == 0 {
+		return Compact{}, errors.New("empty compact value")
+	}
+	if b[0] != CompactVersion1 {
+		return Compact{}, fmt.Errorf("unknown compact version %d", b[0])
+	}
+	if len(b) != CompactLength {
+		return Compact{}, fmt.Errorf("compact value has %d bytes, expected %d", len(b), CompactLength)
+	}
+	c := Compact{
+		Version:     b[0],
+		Flags:       Flags(b[1]),
+		Winner:      Side(b[2]),
+		WinnerScore: b[3],
+		LoserScore:  b[4],
+		BestOf:      b[5],
+		Team1ID:     binary.BigEndian.Uint32(b[6:10]),
+		Team2ID:     binary.BigEndian.Uint32(b[10:14]),
+	}
+	if c.Winner > SideTeam2 {
+		return Compact{}, fmt.Errorf("invalid winner side %d", c.Winner)
+	}
+	if ts := int64(binary.BigEndian.Uint64(b[14:22])); ts != 0 {
+		c.Time = time.Unix(ts, 0)
+	}
+	return c, nil
+}
+
+// Finished returns true if the match has concluded.
+func (c Compact) Finished() bool {
+	return c.Flags&FlagFinished != 0
+}
+
+// Live returns true if the match is currently being played.
+func (c Compact) Live() bool {
+	return c.Flags&FlagLive != 0
+}
+
+// Score returns the score in the format of model.Result, e.g. "2-1". Empty if the match
+// has not concluded.
+func (c Compact) Score() string {
+	if !c.Finished() {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", c.WinnerScore, c.LoserScore)
+}
+
+// parseBestOf parses formats like "bo3". Returns zero if the format is unknown.
+func parseBestOf(format string) uint8 {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(format)), "bo"))
+	if err != nil || n < 0 || n > 255 {
+		return 0
+	}
+	return uint8(n)
+}
+
+// parseScore parses scores like "2-1". Returns zeros if the score cannot be parsed.
+func parseScore(score string) (uint8, uint8) {
+	parts := strings.Split(score, "-")
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	w, errW := strconv.Atoi(strings.TrimSpace(parts[0]))
+	l, errL := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if errW != nil || errL != nil || w < 0 || l < 0 || w > 255 || l > 255 {
+		return 0, 0
+	}
+	return uint8(w), uint8(l)
+}
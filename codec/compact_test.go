@@ -0,0 +1,50 @@
+package codec
+
+import (
+	"github.com/m2q/siam-cs/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCompact_RoundTrip(t *testing.T) {
+	m := model.Match{
+		ID:     2352765,
+		Team1:  model.Team{Name: "Movistar Riders", ID: 7718},
+		Team2:  model.Team{Name: "LDLC", ID: 4674},
+		Date:   time.Unix(1638381600, 0),
+		Format: "bo3",
+		Result: model.Result{Winner: "LDLC", Score: "2-1"},
+	}
+	b := FromMatch(m).Encode()
+	assert.Len(t, b, CompactLength)
+
+	c, err := DecodeCompact(b)
+	assert.Nil(t, err)
+	assert.Equal(t, SideTeam2, c.Winner)
+	assert.Equal(t, "2-1", c.Score())
+	assert.Equal(t, uint8(3), c.BestOf)
+	assert.Equal(t, uint32(7718), c.Team1ID)
+	assert.Equal(t, uint32(4674), c.Team2ID)
+	assert.True(t, c.Finished())
+	assert.False(t, c.Live())
+	assert.True(t, m.Date.Equal(c.Time))
+}
+
+func TestCheckMatch(t *testing.T) {
+	m := model.Match{Team1: model.Team{Name: "G2"}, Team2: model.Team{Name: "NIP"}}
+	assert.Nil(t, CheckMatch(m))
+	m.Result.Winner = "NIP"
+	assert.Nil(t, CheckMatch(m))
+	m.Result.Winner = "Ninjas in Pyjamas"
+	assert.ErrorIs(t, CheckMatch(m), ErrUnknownWinner)
+}
+
+func TestDecodeCompact_Invalid(t *testing.T) {
+	_, err := DecodeCompact(nil)
+	assert.Error(t, err)
+	_, err = DecodeCompact([]byte{2})
+	assert.Error(t, err)
+	_, err = DecodeCompact([]byte{CompactVersion1, 0, 0})
+	assert.Error(t, err)
+}
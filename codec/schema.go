@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetaKey is the name of the reserved key that describes the schema of the published
+// state. It is only present for schemas of version 1 or higher. Like every key, it is
+// prefixed with the namespace of the schema, see KeySchema.Reserved.
+const MetaKey = "schema"
+
+// RootKey is the name of the reserved key that holds the Merkle root over every result the
+// oracle has ever published, if the oracle commits its history. It is prefixed with the
+// namespace of the schema, see KeySchema.Reserved.
+const RootKey = "root"
+
+// LegacyVersion is the schema version of states without a MetaKey: keys are bare match
+// IDs, and values are winner names.
+const LegacyVersion = 0
+
+// Names of the value encodings, see KeySchema.Encoding
+const (
+	// EncodingWinner stores the display name of the winning team
+	EncodingWinner = "winner"
+	// EncodingCompact1 stores values in the format of Compact, version 1
+	EncodingCompact1 = "compact1"
+)
+
+// KeySchema maps match IDs to keys of the published state.
+type KeySchema struct {
+	// Version of the schema. Version LegacyVersion uses bare match IDs as keys and
+	// publishes no MetaKey. Higher versions prefix every key with Source and Game.
+	Version int
+	// Source of the data, e.g. "hltv"
+	Source string
+	// Game of the matches, e.g. "csgo"
+	Game string
+	// Encoding is the name of the value encoding, e.g. "compact1". It is only published
+	// as part of the MetaKey.
+	Encoding string
+}
+
+// Legacy returns true if the schema uses bare match IDs as keys.
+func (s KeySchema) Legacy() bool {
+	return s.Version == LegacyVersion
+}
+
+// Prefix returns the namespace prefix of every key, e.g. "hltv:csgo:". It is empty for the
+// legacy schema.
+func (s KeySchema) Prefix() string {
+	if s.Legacy() {
+		return ""
+	}
+	return s.Source + ":" + s.Game + ":"
+}
+
+// Key returns the key of a match.
+func (s KeySchema) Key(id int) string {
+	return s.Prefix() + strconv.Itoa(id)
+}
+
+// Reserved returns the key of the reserved entry with the given name (MetaKey or RootKey)
+// in the namespace of the schema, e.g. "hltv:csgo:schema". Reserved keys of different
+// namespaces never collide, so several namespaces can coexist on the same application.
+func (s KeySchema) Reserved(name string) string {
+	return s.Prefix() + name
+}
+
+// ParseKey returns the match ID of a key. Returns an error if the key does not belong to
+// the schema.
+func (s KeySchema) ParseKey(key string) (int, error) {
+	p := s.Prefix()
+	if !strings.HasPrefix(key, p) {
+		return 0, fmt.Errorf("key %q does not have prefix %q", key, p)
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(key, p))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("key %q does not contain a valid match ID", key)
+	}
+	return id, nil
+}
+
+// Meta returns the value of the MetaKey, e.g. "v=1;src=hltv;game=csgo;enc=compact1".
+// Returns an empty string for the legacy schema, which has no MetaKey.
+func (s KeySchema) Meta() string {
+	if s.Legacy() {
+		return ""
+	}
+	return fmt.Sprintf("v=%d;src=%s;game=%s;enc=%s", s.Version, s.Source, s.Game, s.Encoding)
+}
+
+// ParseMeta parses the value of a MetaKey, see KeySchema.Meta.
+func ParseMeta(meta string) (KeySchema, error) {
+	var s KeySchema
+	for _, field := range strings.Split(meta, ";") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return KeySchema{}, fmt.Errorf("malformed schema field %q", field)
+		}
+		switch kv[0] {
+		case "v":
+			v, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return KeySchema{}, fmt.Errorf("malformed schema version %q", kv[1])
+			}
+			s.Version = v
+		case "src":
+			s.Source = kv[1]
+		case "game":
+			s.Game = kv[1]
+		case "enc":
+			s.Encoding = kv[1]
+		}
+	}
+	if s.Version <= LegacyVersion {
+		return KeySchema{}, errors.New("schema metadata without version")
+	}
+	return s, nil
+}
@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKeySchema_Keys(t *testing.T) {
+	legacy := KeySchema{}
+	assert.Equal(t, "2352765", legacy.Key(2352765))
+	assert.Equal(t, "", legacy.Meta())
+
+	s := KeySchema{Version: 1, Source: "hltv", Game: "csgo", Encoding: "compact1"}
+	assert.Equal(t, "hltv:csgo:2352765", s.Key(2352765))
+	assert.Equal(t, "hltv:csgo:schema", s.Reserved(MetaKey))
+	assert.Equal(t, "root", legacy.Reserved(RootKey))
+	id, err := s.ParseKey("hltv:csgo:2352765")
+	assert.Nil(t, err)
+	assert.Equal(t, 2352765, id)
+	_, err = s.ParseKey("2352765")
+	assert.Error(t, err)
+	_, err = s.ParseKey("hltv:csgo:abc")
+	assert.Error(t, err)
+}
+
+func TestParseMeta(t *testing.T) {
+	s := KeySchema{Version: 1, Source: "hltv", Game: "csgo", Encoding: "compact1"}
+	parsed, err := ParseMeta(s.Meta())
+	assert.Nil(t, err)
+	assert.Equal(t, s, parsed)
+
+	_, err = ParseMeta("src=hltv")
+	assert.Error(t, err)
+	_, err = ParseMeta("garbage")
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package csgo
+
+import "github.com/m2q/siam-cs/model"
+
+// recordAnnounced remembers the last reported version of every live and upcoming match, so
+// fields that are missing once a match is live or concluded can be completed, see
+// completeMatches. Matches that are neither reported by past nor future are forgotten.
+func (o *Oracle) recordAnnounced(past, future []model.Match) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	announced := make(map[int]model.Match, len(future))
+	for _, m := range past {
+		if prev, ok := o.announced[m.ID]; ok {
+			announced[m.ID] = prev
+		}
+	}
+	for _, m := range future {
+		if prev, ok := o.announced[m.ID]; ok && m.Live {
+			// live matches are reported without their scheduled time
+			m.Date = prev.Date
+		}
+		announced[m.ID] = m
+	}
+	o.announced = announced
+}
+
+// completeMatches returns copies of past and future, in which fields that the API only
+// reports while a match is announced are taken from an earlier version of the match:
+//
+//   - Live matches keep the time they were scheduled for, or first reported at
+//   - Results keep the team IDs and the format of the match
+//
+// Earlier versions are looked up in the matches remembered by recordAnnounced, and in the
+// Archive if configured. This keeps published values stable while a match is live, and
+// complete once it has concluded.
+func (o *Oracle) completeMatches(past, future []model.Match) ([]model.Match, []model.Match) {
+	completedPast := make([]model.Match, len(past))
+	completedFuture := make([]model.Match, len(future))
+	copy(completedPast, past)
+	copy(completedFuture, future)
+	for i, m := range completedFuture {
+		if earlier, ok := o.earlierVersion(m.ID); ok && m.Live {
+			completedFuture[i].Date = earlier.Date
+		}
+	}
+	for i, m := range completedPast {
+		if m.Format != "" && m.Team1.ID != 0 && m.Team2.ID != 0 {
+			continue
+		}
+		earlier, ok := o.earlierVersion(m.ID)
+		if !ok {
+			continue
+		}
+		if m.Format == "" {
+			completedPast[i].Format = earlier.Format
+		}
+		if m.Team1.ID == 0 && m.Team2.ID == 0 {
+			switch {
+			case earlier.Team1.Name == m.Team1.Name && earlier.Team2.Name == m.Team2.Name:
+				completedPast[i].Team1.ID, completedPast[i].Team2.ID = earlier.Team1.ID, earlier.Team2.ID
+			case earlier.Team1.Name == m.Team2.Name && earlier.Team2.Name == m.Team1.Name:
+				completedPast[i].Team1.ID, completedPast[i].Team2.ID = earlier.Team2.ID, earlier.Team1.ID
+			}
+		}
+	}
+	return completedPast, completedFuture
+}
+
+// earlierVersion returns the last announced version of the match with the given ID.
+func (o *Oracle) earlierVersion(id int) (model.Match, bool) {
+	o.mu.Lock()
+	m, ok := o.announced[id]
+	o.mu.Unlock()
+	if ok {
+		return m, true
+	}
+	if o.cfg.Archive == nil {
+		return model.Match{}, false
+	}
+	e, ok := o.cfg.Archive.Get(id)
+	if !ok {
+		return model.Match{}, false
+	}
+	for i := len(e.Versions) - 1; i >= 0; i-- {
+		if m := e.Versions[i].Match; m.Result.Winner == "" {
+			return m, true
+		}
+	}
+	return model.Match{}, false
+}
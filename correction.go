@@ -0,0 +1,223 @@
+package csgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// Statuses of a Correction
+const (
+	// CorrectionApplied means the corrected result was published without approval
+	CorrectionApplied = "applied"
+	// CorrectionPending means the corrected result awaits approval via ApproveCorrection
+	CorrectionPending = "pending"
+	// CorrectionApproved means the corrected result was approved and published
+	CorrectionApproved = "approved"
+)
+
+// Correction is a change of a result that has already been published, which changes the
+// published value.
+type Correction struct {
+	MatchID    int          `json:"match_id"`
+	Old        model.Result `json:"old"`
+	New        model.Result `json:"new"`
+	DetectedAt time.Time    `json:"detected_at"`
+	Status     string       `json:"status"`
+}
+
+func (c Correction) String() string {
+	return fmt.Sprintf("correction of match %d (%s): %s %s -> %s %s",
+		c.MatchID, c.Status, c.Old.Winner, c.Old.Score, c.New.Winner, c.New.Score)
+}
+
+// CorrectionLog is an append-only log of corrections, stored as one JSON object per line.
+type CorrectionLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenCorrectionLog opens the correction log at path. The file is created if it does
+// not exist. Existing entries are never modified.
+func OpenCorrectionLog(path string) (*CorrectionLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &CorrectionLog{file: f}, nil
+}
+
+// Append writes c to the end of the log.
+func (l *CorrectionLog) Append(c Correction) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the underlying file.
+func (l *CorrectionLog) Close() error {
+	return l.file.Close()
+}
+
+// ReadCorrections returns all corrections stored in the correction log at path, in the
+// order they were appended.
+func ReadCorrections(path string) ([]Correction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	corrections := make([]Correction, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var c Correction
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, err
+		}
+		corrections = append(corrections, c)
+	}
+	return corrections, scanner.Err()
+}
+
+// reviewCorrections detects past matches whose published result has changed, such that
+// their published value changes. Every new correction is logged, appended to the
+// CorrectionLog and passed to the OnCorrection hook. If corrections require approval, the
+// old value is kept in the returned desired state until the correction is approved via
+// ApproveCorrection. In DryRun mode, corrections are neither emitted nor remembered.
+func (o *Oracle) reviewCorrections(current, desired map[string]string, past []model.Match, now time.Time) map[string]string {
+	detected := make([]Correction, 0)
+	defer func() {
+		if o.cfg.DryRun {
+			return
+		}
+		for _, c := range detected {
+			o.emitCorrection(c)
+		}
+	}()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.corrections == nil {
+		o.corrections = make(map[int]*Correction)
+	}
+	corrections := o.corrections
+	if o.cfg.DryRun {
+		corrections = make(map[int]*Correction, len(o.corrections))
+		for id, c := range o.corrections {
+			corrections[id] = c
+		}
+	}
+	for _, m := range past {
+		k := o.format().Key(m.ID)
+		v := encodedValue(m, o.format())
+		published, ok := o.published[k]
+		old, unchanged := published.Result, ok && encodedValue(published, o.format()) == v
+		if !ok {
+			// unknown to this instance, compare with buffer instead
+			if cv, found := current[k]; found && cv == v {
+				old, ok, unchanged = m.Result, true, true
+			} else if found {
+				old, _ = o.format().encoding().DecodeResult(cv, m)
+				ok = true
+			}
+		}
+		// changes that do not change the published value, e.g. of the score under the
+		// WinnerNameEncoding, are no corrections
+		if !ok || old.Winner == "" || unchanged {
+			delete(corrections, m.ID)
+			continue
+		}
+		if desired[k] != v {
+			// the new result is not proposed for publication (yet)
+			continue
+		}
+		c, ok := corrections[m.ID]
+		if !ok || c.New != m.Result {
+			c = &Correction{MatchID: m.ID, Old: old, New: m.Result, DetectedAt: now, Status: CorrectionApplied}
+			if o.cfg.ApproveCorrections {
+				c.Status = CorrectionPending
+			}
+			corrections[m.ID] = c
+			detected = append(detected, *c)
+		}
+		if c.Status == CorrectionPending {
+			// hold back until approved
+			if cv, ok := current[k]; ok {
+				desired[k] = cv
+			} else {
+				delete(desired, k)
+			}
+		}
+	}
+	return desired
+}
+
+// emitCorrection logs the correction, appends it to the CorrectionLog and passes it to
+// the OnCorrection hook.
+func (o *Oracle) emitCorrection(c Correction) {
+	log.Print(c)
+	if o.cfg.CorrectionLog != nil {
+		if err := o.cfg.CorrectionLog.Append(c); err != nil {
+			log.Print(err)
+		}
+	}
+	if o.cfg.OnCorrection != nil {
+		o.cfg.OnCorrection(c)
+	}
+}
+
+// PendingCorrections returns all corrections that await approval.
+func (o *Oracle) PendingCorrections() []Correction {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending := make([]Correction, 0)
+	for _, c := range o.corrections {
+		if c.Status == CorrectionPending {
+			pending = append(pending, *c)
+		}
+	}
+	return pending
+}
+
+// ApproveCorrection approves the pending correction of the given match. The corrected
+// result is published in the next cycle. Returns an error if there is no pending
+// correction for the match.
+func (o *Oracle) ApproveCorrection(matchID int) error {
+	o.mu.Lock()
+	c, ok := o.corrections[matchID]
+	if !ok || c.Status != CorrectionPending {
+		o.mu.Unlock()
+		return fmt.Errorf("no pending correction for match %d", matchID)
+	}
+	c.Status = CorrectionApproved
+	approved := *c
+	o.mu.Unlock()
+	o.emitCorrection(approved)
+	return nil
+}
+
+// recordPublished remembers the matches that were published with the desired state, so
+// later changes can be detected as corrections.
+func (o *Oracle) recordPublished(desired map[string]string, index map[string]model.Match) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	published := make(map[string]model.Match, len(desired))
+	for k, v := range desired {
+		if m, ok := index[k]; ok && encodedValue(m, o.format()) == v {
+			published[k] = m
+		} else if old, ok := o.published[k]; ok && encodedValue(old, o.format()) == v {
+			published[k] = old
+		}
+	}
+	o.published = published
+}
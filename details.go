@@ -0,0 +1,256 @@
+package csgo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/m2q/siam-cs/model"
+)
+
+// DefaultDetailInterval is the minimum pause between two requests of match pages, if
+// DetailFetcher.Interval is not set.
+const DefaultDetailInterval = time.Second * 10
+
+// vetoStep matches a step of the map veto: a ban, e.g. "1. OG removed Vertigo", a pick,
+// e.g. "3. Astralis picked Inferno", or the map that was left over, e.g. "7. Dust2 was
+// left over".
+var vetoStep = regexp.MustCompile(`^(\d+)\. (?:(.+) (removed|picked) (.+)|(.+) was left over)$`)
+
+// FetchMaps fetches the page of match m and returns its maps and the maps removed in the
+// veto, see model.Map and model.Ban.
+func (h *HLTV) FetchMaps(ctx context.Context, m model.Match) ([]model.Map, []model.Ban, error) {
+	doc, err := getDocument(ctx, h.client(), h.url(fmt.Sprintf("/matches/%d/match", m.ID)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return h.parseMaps(doc, m)
+}
+
+// parseMaps returns the maps and the bans of the match page of m. Maps that have not been
+// decided yet are omitted.
+func (h *HLTV) parseMaps(doc *goquery.Document, m model.Match) ([]model.Map, []model.Ban, error) {
+	s := h.selectors().Details
+
+	// picks of the veto, by map name
+	picks := make(map[string]model.Map)
+	bans := make([]model.Ban, 0)
+	doc.Find(s.Veto).Each(func(i int, sel *goquery.Selection) {
+		step := vetoStep.FindStringSubmatch(strings.TrimSpace(sel.Text()))
+		if step == nil {
+			return
+		}
+		n, _ := strconv.Atoi(step[1])
+		switch step[3] {
+		case "removed":
+			bans = append(bans, model.Ban{Map: step[4], BannedBy: step[2], VetoStep: n})
+		case "picked":
+			picks[step[4]] = model.Map{Name: step[4], PickedBy: step[2], VetoStep: n}
+		default:
+			picks[step[5]] = model.Map{Name: step[5], VetoStep: n}
+		}
+	})
+
+	maps := make([]model.Map, 0)
+	var err error
+	doc.Find(s.Map).EachWithBreak(func(i int, sel *goquery.Selection) bool {
+		name := strings.TrimSpace(sel.Find(s.MapName).First().Text())
+		if name == "" || name == "TBA" {
+			return true
+		}
+		p := &rowParser{page: fmt.Sprintf("match %d", m.ID), row: i}
+		mp := picks[name]
+		mp.Name = name
+		mp.Team1Rounds = p.rounds("team1_rounds", sel.Find(s.Team1Rounds).First().Text())
+		mp.Team2Rounds = p.rounds("team2_rounds", sel.Find(s.Team2Rounds).First().Text())
+		if len(p.errs) > 0 {
+			err = p.errs[0]
+			return false
+		}
+		maps = append(maps, mp)
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return maps, bans, nil
+}
+
+// DetailFetcher fetches the maps of matches from their HLTV match pages. Matches are
+// queued via Enqueue, and fetched one at a time by Run, with a pause of at least Interval
+// between two requests, so the rate limit of HLTV is not exceeded.
+//
+// If configured in OracleConfig.Details, every match that enters the buffer, or whose
+// published value changes, is queued. Fetched maps are attached to the matches of the
+// following cycles, see model.Match.Maps, until the match leaves the buffer, see Retain.
+type DetailFetcher struct {
+	HLTV *HLTV
+	// Interval is the minimum pause between two requests. Defaults to
+	// DefaultDetailInterval.
+	Interval time.Duration
+
+	mu     sync.Mutex
+	queue  []model.Match
+	queued map[int]bool
+	maps   map[int][]model.Map
+	bans   map[int][]model.Ban
+	// wake signals Run that the queue is not empty anymore
+	wake chan struct{}
+}
+
+// NewDetailFetcher creates a DetailFetcher that fetches match pages from h.
+func NewDetailFetcher(h *HLTV, interval time.Duration) *DetailFetcher {
+	return &DetailFetcher{HLTV: h, Interval: interval}
+}
+
+// init lazily initializes the queue. Must be called with the mutex held.
+func (f *DetailFetcher) init() {
+	if f.queued == nil {
+		f.queued = make(map[int]bool)
+		f.maps = make(map[int][]model.Map)
+		f.bans = make(map[int][]model.Ban)
+		f.wake = make(chan struct{}, 1)
+	}
+}
+
+// Enqueue adds m to the queue, unless it is already queued. It never blocks.
+func (f *DetailFetcher) Enqueue(m model.Match) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	if f.queued[m.ID] {
+		return
+	}
+	f.queued[m.ID] = true
+	f.queue = append(f.queue, m)
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of queued matches.
+func (f *DetailFetcher) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue)
+}
+
+// Maps returns the maps of the match with the given ID, if they have been fetched.
+func (f *DetailFetcher) Maps(id int) ([]model.Map, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	maps, ok := f.maps[id]
+	return maps, ok
+}
+
+// Retain drops the queued and fetched maps of every match whose ID is not in ids, e.g.
+// because the match has left the buffer.
+func (f *DetailFetcher) Retain(ids []int) {
+	keep := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		keep[id] = true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	queue := f.queue[:0]
+	for _, m := range f.queue {
+		if keep[m.ID] {
+			queue = append(queue, m)
+		}
+	}
+	f.queue = queue
+	// this includes the match currently fetched by Run
+	for id := range f.queued {
+		if !keep[id] {
+			delete(f.queued, id)
+		}
+	}
+	for id := range f.maps {
+		if !keep[id] {
+			delete(f.maps, id)
+			delete(f.bans, id)
+		}
+	}
+}
+
+// Annotate returns a copy of matches, where the maps and bans of every match whose maps
+// have been fetched are set.
+func (f *DetailFetcher) Annotate(matches []model.Match) []model.Match {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	annotated := make([]model.Match, len(matches))
+	copy(annotated, matches)
+	for i := range annotated {
+		if maps, ok := f.maps[annotated[i].ID]; ok {
+			annotated[i].Maps = maps
+			annotated[i].Bans = f.bans[annotated[i].ID]
+		}
+	}
+	return annotated
+}
+
+// Run fetches queued matches until ctx is cancelled. Failed requests are logged, and the
+// match is dropped from the queue.
+func (f *DetailFetcher) Run(ctx context.Context) {
+	f.mu.Lock()
+	f.init()
+	wake := f.wake
+	f.mu.Unlock()
+	for {
+		m, ok := f.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+				continue
+			}
+		}
+		maps, bans, err := f.HLTV.FetchMaps(ctx, m)
+		f.mu.Lock()
+		// matches dropped by Retain while fetching are not stored
+		retained := f.queued[m.ID]
+		delete(f.queued, m.ID)
+		if err == nil && retained {
+			f.maps[m.ID] = maps
+			f.bans[m.ID] = bans
+		}
+		f.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("fetching maps of match %d failed: %v", m.ID, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.interval()):
+		}
+	}
+}
+
+// next removes the first match from the queue.
+func (f *DetailFetcher) next() (model.Match, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.queue) == 0 {
+		return model.Match{}, false
+	}
+	m := f.queue[0]
+	f.queue = f.queue[1:]
+	return m, true
+}
+
+// interval returns the configured Interval, or DefaultDetailInterval if none is set.
+func (f *DetailFetcher) interval() time.Duration {
+	if f.Interval == 0 {
+		return DefaultDetailInterval
+	}
+	return f.Interval
+}
@@ -0,0 +1,88 @@
+package csgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m2q/algo-siam/client"
+	"github.com/m2q/siam-cs/generator"
+	"github.com/m2q/siam-cs/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHLTV_ParseMaps(t *testing.T) {
+	doc, err := readDocument(filepath.Join("testdata", "match.html"))
+	assert.Nil(t, err)
+	maps, bans, err := (&HLTV{}).parseMaps(doc, model.Match{ID: 2352765})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Map{
+		{Name: "Inferno", Team1Rounds: 16, Team2Rounds: 13, PickedBy: "OG", VetoStep: 3},
+		{Name: "Nuke", Team1Rounds: 14, Team2Rounds: 16, PickedBy: "Astralis", VetoStep: 4},
+		{Name: "Dust2", Team1Rounds: 19, Team2Rounds: 17, VetoStep: 7},
+	}, maps)
+	assert.Equal(t, []model.Ban{
+		{Map: "Vertigo", BannedBy: "OG", VetoStep: 1},
+		{Map: "Overpass", BannedBy: "Astralis", VetoStep: 2},
+		{Map: "Mirage", BannedBy: "OG", VetoStep: 5},
+		{Map: "Ancient", BannedBy: "Astralis", VetoStep: 6},
+	}, bans)
+}
+
+// Tests if the Oracle queues matches entering the buffer, and attaches their maps
+func TestOracle_Details(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", "match.html"))
+	}))
+	defer srv.Close()
+	details := NewDetailFetcher(&HLTV{BaseURL: srv.URL}, time.Millisecond)
+
+	past, future := generator.GetData(time.Now())
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Details: details})
+	oracle.RunOnce(context.Background())
+	assert.Equal(t, client.GlobalBytes, details.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go details.Run(ctx)
+	last := past[len(past)-1]
+	assert.Eventually(t, func() bool {
+		_, ok := details.Maps(last.ID)
+		return ok
+	}, time.Second*5, time.Millisecond*10)
+
+	annotated := details.Annotate(past)
+	assert.Len(t, annotated[len(annotated)-1].Maps, 3)
+	assert.Len(t, annotated[len(annotated)-1].Bans, 4)
+	// the fetched matches are not modified
+	assert.Nil(t, past[len(past)-1].Maps)
+
+	// maps of matches that left the buffer are forgotten
+	stub.SetMatches(past[:len(past)-1], future)
+	oracle.RunOnce(context.Background())
+	_, ok := details.Maps(last.ID)
+	assert.False(t, ok)
+}
+
+// Tests if Retain removes matches from the queue
+func TestDetailFetcher_Retain(t *testing.T) {
+	past, _ := generator.GetData(time.Now())
+	details := NewDetailFetcher(&HLTV{}, time.Millisecond)
+	for _, m := range past[:3] {
+		details.Enqueue(m)
+	}
+	details.Retain([]int{past[1].ID})
+	assert.Equal(t, 1, details.Len())
+	m, ok := details.next()
+	assert.True(t, ok)
+	assert.Equal(t, past[1].ID, m.ID)
+	// dropped matches can be queued again
+	details.Enqueue(past[0])
+	assert.Equal(t, 1, details.Len())
+}
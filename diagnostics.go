@@ -0,0 +1,128 @@
+package csgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError describes a row of a scraped page that could not be parsed. Invalid rows are
+// skipped, so a ParseError never results in a partially empty match.
+type ParseError struct {
+	// Page is either "results", "matches" or "match {id}" for the page of a single match
+	Page string `json:"page"`
+	// Row is the index of the row on the page
+	Row int `json:"row"`
+	// Field is the name of the invalid field, e.g. "id", "date" or "score_won"
+	Field string `json:"field"`
+	// Raw is the text or attribute value that was parsed
+	Raw    string `json:"raw"`
+	Reason string `json:"reason"`
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s page, row %d: invalid %s %q: %s", e.Page, e.Row, e.Field, e.Raw, e.Reason)
+}
+
+// rowParser parses the fields of a single row, and collects an error for every invalid
+// field. Parsing never panics on malformed input.
+type rowParser struct {
+	page string
+	row  int
+	errs []ParseError
+}
+
+func (p *rowParser) fail(field, raw, reason string) {
+	p.errs = append(p.errs, ParseError{Page: p.page, Row: p.row, Field: field, Raw: raw, Reason: reason})
+}
+
+// matchID parses the match ID of a link like "/matches/2352765/og-vs-astralis".
+func (p *rowParser) matchID(href string) int {
+	parts := strings.Split(strings.TrimPrefix(href, "/matches/"), "/")
+	if !strings.HasPrefix(href, "/matches/") || len(parts) < 2 {
+		p.fail("id", href, "link is not of the form /matches/{id}/{slug}")
+		return 0
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		p.fail("id", href, "match ID is not a positive integer")
+		return 0
+	}
+	return id
+}
+
+// optionalID parses a positive integer, or returns zero if raw is empty.
+func (p *rowParser) optionalID(field, raw string) int {
+	if raw == "" {
+		return 0
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		p.fail(field, raw, "ID is not a positive integer")
+		return 0
+	}
+	return id
+}
+
+// minDate is the earliest plausible date of a match. Earlier timestamps are most likely
+// not in milliseconds.
+var minDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// unixMillis parses a unix timestamp in milliseconds. exists is false if the attribute
+// containing the timestamp is missing.
+func (p *rowParser) unixMillis(field, raw string, exists bool) time.Time {
+	if !exists {
+		p.fail(field, raw, "attribute is missing")
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	date := time.Unix(ms/1000, 0)
+	if err != nil || date.Before(minDate) {
+		p.fail(field, raw, "not a unix timestamp in milliseconds")
+		return time.Time{}
+	}
+	return date
+}
+
+// required returns raw, or fails if it is empty.
+func (p *rowParser) required(field, raw string) string {
+	if raw == "" {
+		p.fail(field, raw, "missing")
+	}
+	return raw
+}
+
+// score returns raw, or fails if it is not a non-negative integer.
+func (p *rowParser) score(field, raw string) string {
+	if n, err := strconv.Atoi(raw); err != nil || n < 0 {
+		p.fail(field, raw, "not a non-negative integer")
+	}
+	return raw
+}
+
+// rounds parses the number of rounds won on a map. Maps that have not been played yet
+// have no score, which is parsed as zero.
+func (p *rowParser) rounds(field, raw string) int {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "-" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		p.fail(field, raw, "not a non-negative integer")
+		return 0
+	}
+	return n
+}
+
+// countErrors returns the number of rows with at least one error in the given field.
+func countErrors(errs []ParseError, field string) int {
+	rows := make(map[int]bool)
+	for _, e := range errs {
+		if e.Field == field {
+			rows[e.Row] = true
+		}
+	}
+	return len(rows)
+}
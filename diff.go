@@ -0,0 +1,107 @@
+package csgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// DiffEntry is a single key that differs between the published and the desired state.
+type DiffEntry struct {
+	Key string `json:"key"`
+	// Old is the published value. Empty for added keys.
+	Old string `json:"old,omitempty"`
+	// New is the desired value. Empty for deleted keys.
+	New string `json:"new,omitempty"`
+	// Match is the fetched match belonging to Key, if known.
+	Match *model.Match `json:"match,omitempty"`
+}
+
+// Diff lists the changes necessary to turn the published state into a desired state.
+type Diff struct {
+	Add    []DiffEntry `json:"add"`
+	Update []DiffEntry `json:"update"`
+	Delete []DiffEntry `json:"delete"`
+}
+
+// ComputeDiff returns the changes necessary to turn current into desired. Entries are
+// annotated with matches from index (see IndexMatches) and ordered by match ID.
+func ComputeDiff(current, desired map[string]string, index map[string]model.Match) *Diff {
+	d := &Diff{Add: []DiffEntry{}, Update: []DiffEntry{}, Delete: []DiffEntry{}}
+	entry := func(k string) DiffEntry {
+		e := DiffEntry{Key: k, Old: current[k], New: desired[k]}
+		if m, ok := index[k]; ok {
+			e.Match = &m
+		}
+		return e
+	}
+	for k, v := range desired {
+		old, ok := current[k]
+		if !ok {
+			d.Add = append(d.Add, entry(k))
+		} else if old != v {
+			d.Update = append(d.Update, entry(k))
+		}
+	}
+	for k := range current {
+		if _, ok := desired[k]; !ok {
+			d.Delete = append(d.Delete, entry(k))
+		}
+	}
+	for _, entries := range [][]DiffEntry{d.Add, d.Update, d.Delete} {
+		sort.Slice(entries, func(i, j int) bool { return keyLess(entries[i].Key, entries[j].Key) })
+	}
+	return d
+}
+
+// Empty returns true if there are no changes.
+func (d *Diff) Empty() bool {
+	return len(d.Add)+len(d.Update)+len(d.Delete) == 0
+}
+
+// WriteText writes a human-readable representation of the diff to w.
+func (d *Diff) WriteText(w io.Writer) error {
+	if d.Empty() {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	sections := []struct {
+		sign    string
+		entries []DiffEntry
+	}{{"+", d.Add}, {"~", d.Update}, {"-", d.Delete}}
+	for _, s := range sections {
+		for _, e := range s.entries {
+			line := fmt.Sprintf("%s %s", s.sign, e.Key)
+			switch s.sign {
+			case "+":
+				line += fmt.Sprintf(" = %q", e.New)
+			case "~":
+				line += fmt.Sprintf(" = %q (was %q)", e.New, e.Old)
+			case "-":
+				line += fmt.Sprintf(" (was %q)", e.Old)
+			}
+			if e.Match != nil {
+				line += fmt.Sprintf("  [%s vs %s, %s, %s]", e.Match.Team1.Name, e.Match.Team2.Name,
+					e.Match.Event.Name, e.Match.Date.Format("2006-01-02 15:04 MST"))
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d to add, %d to update, %d to delete\n", len(d.Add), len(d.Update), len(d.Delete))
+	return err
+}
+
+// WriteJSON writes an indented JSON representation of the diff to w.
+func (d *Diff) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(d, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,118 @@
+package csgo
+
+import (
+	"github.com/m2q/siam-cs/codec"
+	"github.com/m2q/siam-cs/model"
+)
+
+// ValueEncoding determines how a match is represented as a value on the buffer.
+type ValueEncoding interface {
+	// Name identifies the encoding in the schema metadata, see codec.MetaKey.
+	Name() string
+	// EncodeValue returns the value of a match.
+	EncodeValue(m model.Match) string
+	// DecodeResult recovers the result of match m from a published value.
+	DecodeResult(value string, m model.Match) (model.Result, error)
+}
+
+// valueChecker is implemented by encodings that cannot represent every match. Matches
+// that fail the check are left out of the state, see encodeMatch.
+type valueChecker interface {
+	CheckValue(m model.Match) error
+}
+
+// WinnerNameEncoding is the default ValueEncoding. The value is the display name of the
+// winning team, or empty if there is no winner yet.
+type WinnerNameEncoding struct{}
+
+func (WinnerNameEncoding) Name() string { return codec.EncodingWinner }
+
+func (WinnerNameEncoding) EncodeValue(m model.Match) string {
+	return m.Result.Winner
+}
+
+func (WinnerNameEncoding) DecodeResult(value string, m model.Match) (model.Result, error) {
+	return model.Result{Winner: value}, nil
+}
+
+// CompactEncoding encodes matches in the versioned binary format of codec.Compact. It
+// contains the winner side, score, format, status, team IDs and time of a match.
+type CompactEncoding struct{}
+
+func (CompactEncoding) Name() string { return codec.EncodingCompact1 }
+
+func (CompactEncoding) EncodeValue(m model.Match) string {
+	return string(codec.FromMatch(m).Encode())
+}
+
+// CheckValue returns an error if the winner of m cannot be encoded, see codec.CheckMatch.
+func (CompactEncoding) CheckValue(m model.Match) error {
+	return codec.CheckMatch(m)
+}
+
+func (CompactEncoding) DecodeResult(value string, m model.Match) (model.Result, error) {
+	c, err := codec.DecodeCompact([]byte(value))
+	if err != nil {
+		return model.Result{}, err
+	}
+	r := model.Result{Score: c.Score()}
+	switch c.Winner {
+	case codec.SideTeam1:
+		r.Winner = m.Team1.Name
+	case codec.SideTeam2:
+		r.Winner = m.Team2.Name
+	}
+	return r, nil
+}
+
+// Format combines the key schema and the value encoding of the published state. The zero
+// value is the legacy format: bare match IDs as keys and winner names as values.
+type Format struct {
+	Keys codec.KeySchema
+	// Encoding of the values. Defaults to WinnerNameEncoding.
+	Encoding ValueEncoding
+}
+
+// encoding returns the configured ValueEncoding, or WinnerNameEncoding if none is set.
+func (f Format) encoding() ValueEncoding {
+	if f.Encoding == nil {
+		return WinnerNameEncoding{}
+	}
+	return f.Encoding
+}
+
+// Key returns the buffer key of a match.
+func (f Format) Key(id int) string {
+	return f.Keys.Key(id)
+}
+
+// Reserved returns the number of buffer slots reserved for metadata.
+func (f Format) Reserved() int {
+	if f.Keys.Legacy() {
+		return 0
+	}
+	return 1
+}
+
+// Metadata returns the metadata entries of the format. Empty for the legacy schema.
+func (f Format) Metadata() map[string]string {
+	meta := make(map[string]string)
+	if !f.Keys.Legacy() {
+		s := f.Keys
+		s.Encoding = f.encoding().Name()
+		meta[s.Reserved(codec.MetaKey)] = s.Meta()
+	}
+	return meta
+}
+
+// matchEntries returns the entries of state that hold matches, i.e. without the metadata
+// and the history root.
+func (f Format) matchEntries(state map[string]string) map[string]string {
+	entries := make(map[string]string, len(state))
+	for k, v := range state {
+		if k != f.Keys.Reserved(codec.MetaKey) && k != f.Keys.Reserved(codec.RootKey) {
+			entries[k] = v
+		}
+	}
+	return entries
+}
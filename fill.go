@@ -0,0 +1,33 @@
+package csgo
+
+import (
+	"github.com/m2q/algo-siam/client"
+	"github.com/m2q/siam-cs/model"
+)
+
+// fillFromArchive returns past, preceded by the most recent archived results that are
+// older than every result in past, and neither reported by past nor future. At most as
+// many results are added as the buffer has slots. Past stays in chronological order.
+func (o *Oracle) fillFromArchive(past, future []model.Match) []model.Match {
+	reported := make(map[int]bool, len(past)+len(future))
+	for _, matches := range [][]model.Match{past, future} {
+		for _, m := range matches {
+			reported[m.ID] = true
+		}
+	}
+	var older func(model.Match) bool
+	if len(past) > 0 {
+		oldest := past[0].Date
+		older = func(m model.Match) bool { return m.Date.Before(oldest) }
+	}
+	archived := make([]model.Match, 0)
+	for _, e := range o.cfg.Archive.Select(func(m model.Match) bool {
+		return m.Result.Winner != "" && !reported[m.ID] && (older == nil || older(m))
+	}) {
+		archived = append(archived, e.Latest())
+	}
+	if len(archived) > client.GlobalBytes {
+		archived = archived[len(archived)-client.GlobalBytes:]
+	}
+	return append(archived, past...)
+}
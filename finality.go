@@ -0,0 +1,134 @@
+package csgo
+
+import (
+	"time"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// FinalityWindow defines when the result of a past match is considered final and may be
+// published. Results are sometimes corrected by the source shortly after a match, so
+// publishing them immediately risks putting wrong data on the chain. If both fields are
+// set, both conditions must be met. The zero value publishes results immediately.
+type FinalityWindow struct {
+	// Observations is the number of consecutive fetches in which a result must be
+	// observed identically.
+	Observations int
+	// Duration is the minimum time between the first and the latest identical observation
+	// of a result.
+	Duration time.Duration
+}
+
+// observation tracks a result that is not final yet.
+type observation struct {
+	result model.Result
+	count  int
+	since  time.Time
+}
+
+// enabled returns true if results have to be delayed at all.
+func (w FinalityWindow) enabled() bool {
+	return w.Observations > 1 || w.Duration > 0
+}
+
+// final returns true if the observation satisfies the finality window at time now.
+func (w FinalityWindow) final(obs *observation, now time.Time) bool {
+	return obs.count >= w.Observations && now.Sub(obs.since) >= w.Duration
+}
+
+// applyFinality returns a copy of past, in which every result that is not final yet is
+// replaced by the last final result of the match, or an empty result if there is none.
+// Results are tracked across calls, so applyFinality must be called once per fetch.
+//
+// Results that are already published in current are considered final, even if they have
+// not been observed by this instance yet, e.g. after a restart. This way, a published
+// winner is never replaced by an empty result.
+func (o *Oracle) applyFinality(past []model.Match, current map[string]string, now time.Time) []model.Match {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending == nil {
+		o.pending = make(map[int]*observation)
+		o.final = make(map[int]model.Result)
+	}
+	pending, final := o.pending, o.final
+	if o.cfg.DryRun {
+		// a dry run must not advance the finality window
+		pending, final = make(map[int]*observation, len(o.pending)), make(map[int]model.Result, len(o.final))
+		for id, obs := range o.pending {
+			c := *obs
+			pending[id] = &c
+		}
+		for id, r := range o.final {
+			final[id] = r
+		}
+	}
+	seen := make(map[int]bool, len(past))
+	result := make([]model.Match, len(past))
+	copy(result, past)
+	for i, m := range result {
+		seen[m.ID] = true
+		if _, ok := final[m.ID]; !ok {
+			if published, ok := o.publishedResult(current, m); ok {
+				final[m.ID] = published
+			}
+		}
+		if r, ok := final[m.ID]; ok && r == m.Result {
+			delete(pending, m.ID)
+			continue
+		}
+		obs, ok := pending[m.ID]
+		if !ok || obs.result != m.Result {
+			obs = &observation{result: m.Result, since: now}
+			pending[m.ID] = obs
+		}
+		obs.count++
+		if o.cfg.Finality.final(obs, now) {
+			final[m.ID] = m.Result
+			delete(pending, m.ID)
+			continue
+		}
+		// hold back result until it is final
+		result[i].Result = final[m.ID]
+	}
+	// forget matches that are no longer reported
+	for id := range pending {
+		if !seen[id] {
+			delete(pending, id)
+		}
+	}
+	for id := range final {
+		if !seen[id] {
+			delete(final, id)
+		}
+	}
+	return result
+}
+
+// publishedResult returns the result of match m that is published in state, if there is
+// one with a winner.
+func (o *Oracle) publishedResult(state map[string]string, m model.Match) (model.Result, bool) {
+	v, ok := state[o.format().Key(m.ID)]
+	if !ok {
+		return model.Result{}, false
+	}
+	if v == encodedValue(m, o.format()) {
+		return m.Result, m.Result.Winner != ""
+	}
+	r, err := o.format().encoding().DecodeResult(v, m)
+	if err != nil || r.Winner == "" {
+		return model.Result{}, false
+	}
+	return r, true
+}
+
+// PendingResults returns the results that have been observed, but are not final yet,
+// indexed by match ID.
+func (o *Oracle) PendingResults() map[int]model.Result {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending := make(map[int]model.Result, len(o.pending))
+	for id, obs := range o.pending {
+		pending[id] = obs.result
+	}
+	return pending
+}
@@ -6,6 +6,7 @@ package generator
 import (
 	_ "embed"
 	"encoding/json"
+	"github.com/m2q/siam-cs/clock"
 	"github.com/m2q/siam-cs/model"
 	"time"
 )
@@ -88,8 +89,9 @@ func NormalizeTime(past []model.Match, future []model.Match, refTime time.Time)
 }
 
 // ProgressTime lets a specified number of future matches conclude, and
-// re-normalizes the time. Effectively, this simulates a passing of time.
-func ProgressTime(past, future []model.Match, matchCount int) ([]model.Match, []model.Match) {
+// re-normalizes the time to the current time of c. Effectively, this simulates
+// a passing of time.
+func ProgressTime(past, future []model.Match, matchCount int, c clock.Clock) ([]model.Match, []model.Match) {
 	// matchCount can't exceed future slice length
 	if matchCount > len(future) {
 		matchCount = len(future)
@@ -106,6 +108,6 @@ func ProgressTime(past, future []model.Match, matchCount int) ([]model.Match, []
 	past = append(past, future[:matchCount]...)
 	future = future[matchCount:]
 
-	NormalizeTime(past, future, time.Now())
+	NormalizeTime(past, future, c.Now())
 	return past, future
 }
@@ -0,0 +1,84 @@
+package csgo
+
+import "fmt"
+
+// SafetyGuard detects anomalous fetches, e.g. caused by a markup change on the scraped
+// website, and prevents them from being published. The zero value refuses empty fetches
+// and desired states that would wipe the whole buffer.
+type SafetyGuard struct {
+	// MinMatches is the minimum number of matches (past and future) a fetch must
+	// contain. Values below 1 are treated as 1, so empty fetches are always refused.
+	MinMatches int
+
+	// MaxDropRatio is the maximum relative drop of the number of fetched matches,
+	// compared to the last accepted fetch. E.g. 0.5 refuses fetches with less than half
+	// the matches of the last accepted fetch. Zero disables the check.
+	MaxDropRatio float64
+
+	// MaxDeletions is the maximum number of keys that may be removed from the buffer in
+	// a single cycle. Zero disables the check.
+	MaxDeletions int
+
+	// MaxParseErrors is the maximum number of rows the PrimaryAPI may skip because they
+	// could not be parsed, if it implements DiagnosticAPI. Zero disables the check.
+	MaxParseErrors int
+}
+
+// AnomalyError is returned if the SafetyGuard refuses to publish a fetch or desired state.
+type AnomalyError struct {
+	Reason string
+}
+
+func (e *AnomalyError) Error() string {
+	return "ALERT: refusing to publish anomalous state: " + e.Reason
+}
+
+// checkFetch returns an AnomalyError if a fetch of n matches is anomalous. last is the
+// number of matches of the last accepted fetch, or zero if there is none.
+func (g SafetyGuard) checkFetch(n, last int) error {
+	min := g.MinMatches
+	if min < 1 {
+		min = 1
+	}
+	if n < min {
+		return &AnomalyError{fmt.Sprintf("fetched %d matches, expected at least %d", n, min)}
+	}
+	if g.MaxDropRatio > 0 && last > 0 && float64(n) < float64(last)*(1-g.MaxDropRatio) {
+		return &AnomalyError{fmt.Sprintf("fetched %d matches, down from %d in last accepted fetch", n, last)}
+	}
+	return nil
+}
+
+// checkParse returns an AnomalyError if too many rows of a fetch could not be parsed.
+func (g SafetyGuard) checkParse(diag []ParseError) error {
+	type row struct {
+		page  string
+		index int
+	}
+	invalid := make(map[row]bool)
+	for _, e := range diag {
+		invalid[row{e.Page, e.Row}] = true
+	}
+	if g.MaxParseErrors > 0 && len(invalid) > g.MaxParseErrors {
+		return &AnomalyError{fmt.Sprintf("%d rows could not be parsed, limit is %d", len(invalid), g.MaxParseErrors)}
+	}
+	return nil
+}
+
+// checkState returns an AnomalyError if moving the buffer from current to desired
+// would collapse the published state.
+func (g SafetyGuard) checkState(current, desired map[string]string) error {
+	if len(desired) == 0 && len(current) > 0 {
+		return &AnomalyError{fmt.Sprintf("desired state is empty, would delete all %d keys", len(current))}
+	}
+	deletions := 0
+	for k := range current {
+		if _, ok := desired[k]; !ok {
+			deletions++
+		}
+	}
+	if g.MaxDeletions > 0 && deletions > g.MaxDeletions {
+		return &AnomalyError{fmt.Sprintf("desired state deletes %d keys, limit is %d", deletions, g.MaxDeletions)}
+	}
+	return nil
+}
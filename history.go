@@ -0,0 +1,170 @@
+package csgo
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/m2q/siam-cs/codec"
+	"github.com/m2q/siam-cs/merkle"
+	"github.com/m2q/siam-cs/model"
+)
+
+// ErrNotPublished is returned by Oracle.Proof for matches whose result is not part of the
+// committed history.
+var ErrNotPublished = errors.New("result has not been published")
+
+// ErrNoArchive is logged every cycle if CommitHistory is set without an Archive. Without
+// an Archive, the history would start empty after a restart, and the new root would
+// invalidate every proof served before.
+var ErrNoArchive = errors.New("CommitHistory requires an Archive, refusing to publish a history root")
+
+// HistoryEntry is a result published by the Oracle, which is committed to by the history
+// root (see codec.RootKey).
+type HistoryEntry struct {
+	Match model.Match `json:"match"`
+	// Key and Value are the published key-value pair. Value is binary in the compact
+	// encoding, so it is stored as bytes.
+	Key         string    `json:"key"`
+	Value       []byte    `json:"value"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// HistoryProof proves that a result has been published by the Oracle. Proof verifies
+// against Root, which is the value of codec.RootKey on the buffer.
+type HistoryProof struct {
+	Entry HistoryEntry  `json:"entry"`
+	Root  []byte        `json:"root"`
+	Proof *merkle.Proof `json:"proof"`
+}
+
+// updateHistory returns the history extended by every result contained in desired, and
+// the Merkle tree over it. Results are only added if the published value belongs to the
+// result of the match, and replace earlier versions of the same match.
+func (o *Oracle) updateHistory(desired map[string]string, index map[string]model.Match, now time.Time) (map[string]HistoryEntry, *merkle.Tree, error) {
+	if o.cfg.Archive == nil {
+		return nil, nil, ErrNoArchive
+	}
+	o.mu.Lock()
+	if o.tree == nil {
+		o.history = o.archivedHistory()
+	}
+	history := make(map[string]HistoryEntry, len(o.history)+len(desired))
+	for k, e := range o.history {
+		history[k] = e
+	}
+	o.mu.Unlock()
+	for k, v := range desired {
+		m, ok := index[k]
+		if !ok || m.Result.Winner == "" || encodedValue(m, o.format()) != v {
+			continue
+		}
+		if e, ok := history[k]; ok && string(e.Value) == v {
+			continue
+		}
+		history[k] = HistoryEntry{Match: m, Key: k, Value: []byte(v), PublishedAt: now}
+	}
+	entries := make(map[string][]byte, len(history))
+	for k, e := range history {
+		entries[k] = e.Value
+	}
+	tree, err := merkle.New(entries)
+	return history, tree, err
+}
+
+// archivedHistory restores the history from the publications stored in the Archive, so
+// the history root survives a restart of the Oracle.
+func (o *Oracle) archivedHistory() map[string]HistoryEntry {
+	history := make(map[string]HistoryEntry)
+	for _, e := range o.cfg.Archive.Select(nil) {
+		for _, p := range e.Publications {
+			if !p.Removed && p.Match.Result.Winner != "" && p.Key == o.format().Key(e.ID) {
+				history[p.Key] = HistoryEntry{Match: p.Match, Key: p.Key, Value: p.Value, PublishedAt: p.Time}
+			}
+		}
+	}
+	return history
+}
+
+// archivePublished stores the published changes in the Archive. Only values that represent
+// the fetched match are recorded as publications.
+func (o *Oracle) archivePublished(d *Diff) {
+	now := o.clock().Now()
+	for _, e := range append(d.Add, d.Update...) {
+		if e.Match == nil || encodedValue(*e.Match, o.format()) != e.New {
+			continue
+		}
+		if err := o.cfg.Archive.RecordPublish(now, *e.Match, e.Key, []byte(e.New)); err != nil {
+			log.Print(err)
+		}
+	}
+	for _, e := range d.Delete {
+		id, err := o.format().Keys.ParseKey(e.Key)
+		if err != nil {
+			continue
+		}
+		if err := o.cfg.Archive.RecordRemoval(now, id, e.Key); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// commitHistory replaces the committed history, after it has been published.
+func (o *Oracle) commitHistory(history map[string]HistoryEntry, tree *merkle.Tree) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.history, o.tree = history, tree
+}
+
+// History returns every result committed to by the current history root.
+func (o *Oracle) History() []HistoryEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entries := make([]HistoryEntry, 0, len(o.history))
+	for _, e := range o.history {
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Proof returns the inclusion proof of the result of a match in the committed history.
+// Returns an error if CommitHistory is disabled, or the result has not been published.
+func (o *Oracle) Proof(matchID int) (*HistoryProof, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.tree == nil {
+		return nil, errors.New("no history has been committed")
+	}
+	k := o.format().Key(matchID)
+	e, ok := o.history[k]
+	if !ok {
+		return nil, ErrNotPublished
+	}
+	p, err := o.tree.Proof(k)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryProof{Entry: e, Root: o.tree.Root(), Proof: p}, nil
+}
+
+// setRoot remembers the history root contained in the published state, so proofs can be
+// checked against it without reading the Publisher, see ProofHandler.
+func (o *Oracle) setRoot(state map[string]string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.root = state[o.rootKey()]
+}
+
+// rootKey returns the key of the history root in the namespace of the KeySchema.
+func (o *Oracle) rootKey() string {
+	return o.format().Keys.Reserved(codec.RootKey)
+}
+
+// reserved returns the number of buffer slots reserved for metadata and the history root.
+func (o *Oracle) reserved() int {
+	n := o.format().Reserved()
+	if o.cfg.CommitHistory {
+		n++
+	}
+	return n
+}
@@ -28,42 +28,206 @@ SOFTWARE.
 package csgo
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/m2q/siam-cs/model"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// DefaultRequestTimeout is the timeout of a single HLTV request, if HLTV.Timeout is not set.
+const DefaultRequestTimeout = time.Second * 30
+
+// DefaultPageDelay is the pause between two requests of result pages, if HLTV.PageDelay
+// is not set.
+const DefaultPageDelay = time.Second * 5
+
+// ResultsPerPage is the number of results on a single results page of HLTV.
+const ResultsPerPage = 100
+
+// DefaultBaseURL is the address of HLTV, if HLTV.BaseURL is not set.
+const DefaultBaseURL = "https://www.hltv.org"
+
 type HLTV struct {
 	UpcomingPage *goquery.Document
 	ResultsPage  *goquery.Document
+
+	// Selectors are the CSS selectors used to parse the pages. Defaults to DefaultSelectors,
+	// see LoadSelectors to load them from a config file.
+	Selectors *Selectors
+
+	// Layout configures the structural checks of parsed pages. If a page fails a check,
+	// Fetch returns a LayoutChangedError.
+	Layout LayoutChecks
+
+	// ResultsDepth is the number of results pages read by Fetch, each containing
+	// ResultsPerPage results. Defaults to 1.
+	ResultsDepth int
+
+	// PageDelay is the pause between two requests of results pages, to not exceed the
+	// rate limit of HLTV. Defaults to DefaultPageDelay.
+	PageDelay time.Duration
+
+	// BaseURL is the address of HLTV. Defaults to DefaultBaseURL.
+	BaseURL string
+
+	// Timeout limits the duration of a single request, including reading the response
+	// body. Defaults to DefaultRequestTimeout.
+	Timeout time.Duration
+}
+
+// client returns a http.Client that respects the configured request timeout.
+func (h *HLTV) client() *http.Client {
+	if h.Timeout == 0 {
+		return &http.Client{Timeout: DefaultRequestTimeout}
+	}
+	return &http.Client{Timeout: h.Timeout}
 }
 
-// Fetch gets the latest version of the HLTV page.
+// Fetch gets the latest version of the HLTV page. Pending requests are aborted when
+// ctx is cancelled.
 // Note: Do not abuse this function. Exceeding certain rates can be interpreted as
 // crawling and result in IP ban.
-func (h *HLTV) Fetch() (past, future []model.Match, err error) {
-	h.UpcomingPage, err = getDocument("https://www.hltv.org/matches?predefinedFilter=top_tier")
+func (h *HLTV) Fetch(ctx context.Context) (past, future []model.Match, err error) {
+	past, future, _, err = h.FetchWithDiagnostics(ctx)
+	return past, future, err
+}
+
+// FetchWithDiagnostics is like Fetch, but additionally returns every row that could not
+// be parsed, see ParseWithDiagnostics.
+func (h *HLTV) FetchWithDiagnostics(ctx context.Context) (past, future []model.Match, diag []ParseError, err error) {
+	c := h.client()
+	h.UpcomingPage, err = getDocument(ctx, c, h.url("/matches?predefinedFilter=top_tier"))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	h.ResultsPage, err = getDocument("https://www.hltv.org/results?stars=1")
+	h.ResultsPage, err = getDocument(ctx, c, h.resultsURL(0))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
+	past, future, diag, err = h.ParseWithDiagnostics()
+	if err != nil {
+		return nil, nil, diag, err
+	}
+	// older results are on the following pages
+	for page := 1; page < h.ResultsDepth; page++ {
+		older, errs, err := h.FetchResults(ctx, page*ResultsPerPage)
+		diag = append(diag, errs...)
+		if err != nil {
+			return nil, nil, diag, err
+		}
+		if len(older) == 0 {
+			break
+		}
+		past = mergeResults(older, past)
+	}
+	return past, future, diag, nil
+}
 
-	past, err = h.getPastMatches()
+// FetchResults fetches the results page at the given offset, which should be a multiple
+// of ResultsPerPage, and returns its results ordered from oldest to newest. Pages after
+// offset zero are only requested after waiting for PageDelay. An empty result means that
+// there are no older results.
+func (h *HLTV) FetchResults(ctx context.Context, offset int) ([]model.Match, []ParseError, error) {
+	if offset > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(h.pageDelay()):
+		}
+	}
+	doc, err := getDocument(ctx, h.client(), h.resultsURL(offset))
 	if err != nil {
 		return nil, nil, err
 	}
-	future, err = h.getFutureMatches()
+	past, rows, diag := h.parseResults(doc, offset)
+	if rows == 0 && offset > 0 {
+		return past, diag, nil
+	}
+	if err := h.Layout.checkResults(rows, past, diag); err != nil {
+		return nil, diag, err
+	}
+	return past, diag, nil
+}
+
+// mergeResults appends newer results to older ones. Results that moved to the next page
+// between two requests are only contained once.
+func mergeResults(older, newer []model.Match) []model.Match {
+	seen := make(map[int]bool, len(newer))
+	for _, m := range newer {
+		seen[m.ID] = true
+	}
+	merged := make([]model.Match, 0, len(older)+len(newer))
+	for _, m := range older {
+		if !seen[m.ID] {
+			merged = append(merged, m)
+		}
+	}
+	return append(merged, newer...)
+}
+
+// url returns the address of the given path on HLTV.
+func (h *HLTV) url(path string) string {
+	if h.BaseURL == "" {
+		return DefaultBaseURL + path
+	}
+	return h.BaseURL + path
+}
+
+// resultsURL returns the address of the results page at the given offset.
+func (h *HLTV) resultsURL(offset int) string {
+	if offset == 0 {
+		return h.url("/results?stars=1")
+	}
+	return h.url(fmt.Sprintf("/results?offset=%d&stars=1", offset))
+}
+
+// pageDelay returns the configured PageDelay, or DefaultPageDelay if none is set.
+func (h *HLTV) pageDelay() time.Duration {
+	if h.PageDelay == 0 {
+		return DefaultPageDelay
+	}
+	return h.PageDelay
+}
+
+// Parse returns the matches of the current UpcomingPage and ResultsPage, without fetching
+// them. This is useful to check Selectors against saved pages. Returns a
+// LayoutChangedError if a page fails the Layout checks.
+func (h *HLTV) Parse() (past, future []model.Match, err error) {
+	past, future, _, err = h.ParseWithDiagnostics()
+	return past, future, err
+}
+
+// ParseWithDiagnostics is like Parse, but additionally returns a ParseError for every
+// row that could not be parsed. Such rows are skipped. The diagnostics are returned even
+// if a page fails the Layout checks.
+func (h *HLTV) ParseWithDiagnostics() (past, future []model.Match, diag []ParseError, err error) {
+	past, rows, diag := h.getPastMatches()
+	if err = h.Layout.checkResults(rows, past, diag); err != nil {
+		return nil, nil, diag, err
+	}
+	future, rows, errs := h.getFutureMatches()
+	diag = append(diag, errs...)
+	if err = h.Layout.checkMatches("matches", rows, future, errs); err != nil {
+		return nil, nil, diag, err
+	}
+	return past, future, diag, nil
+}
+
+// ReadPages sets UpcomingPage and ResultsPage to the saved HTML pages at the given paths,
+// e.g. to check Selectors via Parse.
+func (h *HLTV) ReadPages(upcoming, results string) (err error) {
+	h.UpcomingPage, err = readDocument(upcoming)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	return past, future, nil
+	h.ResultsPage, err = readDocument(results)
+	return err
 }
 
 func PopSlashSource(selection *goquery.Selection) string {
@@ -72,32 +236,45 @@ func PopSlashSource(selection *goquery.Selection) string {
 	return split[len(split)-1]
 }
 
-func (h *HLTV) getPastMatches() ([]model.Match, error) {
-	doc := h.ResultsPage
+// getPastMatches returns the valid matches of the results page, the number of rows on the
+// page and the errors of all invalid rows.
+func (h *HLTV) getPastMatches() ([]model.Match, int, []ParseError) {
+	return h.parseResults(h.ResultsPage, 0)
+}
+
+// parseResults returns the valid matches of a results page, the number of rows on the
+// page and the errors of all invalid rows. Row indices of errors start at offset.
+func (h *HLTV) parseResults(doc *goquery.Document, offset int) ([]model.Match, int, []ParseError) {
+	s := h.selectors().Results
 
 	matches := make([]model.Match, 0, 100)
+	errs := make([]ParseError, 0)
+
+	rows := doc.Find(s.Row)
+	rows.Each(func(i int, sel *goquery.Selection) {
+		p := &rowParser{page: "results", row: offset + i}
+		selection := sel.Find(s.Result).First()
+
+		href, _ := selection.Parent().Attr("href")
+		matchID := p.matchID(href)
+		event := selection.Find(s.Event).First().Text()
+		timeRaw, exists := sel.Attr(s.TimeAttr)
+		date := p.unixMillis("date", timeRaw, exists)
+		team1 := p.required("team1", selection.Find(s.Team1).First().Find(s.TeamName).First().Text())
+		team2 := p.required("team2", selection.Find(s.Team2).First().Find(s.TeamName).First().Text())
+
+		winner := selection.Find(s.Winner).First().Text()
+		if winner == "" || (winner != team1 && winner != team2) {
+			p.fail("winner", winner, "winner is not one of the teams")
+		}
 
-	doc.Find(".result-con").Each(func(i int, sel *goquery.Selection) {
-		selection := sel.Find(".result").First()
+		scoreWon := p.score("score_won", selection.Find(s.ScoreWon).First().Text())
+		scoreLost := p.score("score_lost", selection.Find(s.ScoreLost).First().Text())
 
-		tmp, _ := selection.Parent().Attr("href")
-		matchID, _ := strconv.Atoi(strings.Split(strings.TrimPrefix(tmp, "/matches/"), "/")[0])
-		event := selection.Find(".event-name").First().Text()
-		timeRaw, exists := sel.Attr("data-zonedgrouping-entry-unix")
-		date := time.Time{}
-		if exists {
-			matchTime, _ := strconv.ParseInt(timeRaw[:len(timeRaw)-3], 10, 64)
-			date = time.Unix(matchTime, 0)
-		} else {
+		if len(p.errs) > 0 {
+			errs = append(errs, p.errs...)
 			return
 		}
-		team1 := selection.Find(".team1").First().Find(".team").First().Text()
-		team2 := selection.Find(".team2").First().Find(".team").First().Text()
-
-		winner := selection.Find(".team-won").First().Text()
-
-		scoreWon := selection.Find(".score-won").First().Text()
-		scoreLost := selection.Find(".score-lost").First().Text()
 
 		match := model.Match{
 			ID: matchID,
@@ -121,50 +298,63 @@ func (h *HLTV) getPastMatches() ([]model.Match, error) {
 	})
 	// past matches are in reverse order on hltv page
 	ReverseMatches(matches)
-	return matches, nil
+	return matches, rows.Length(), errs
 }
 
-func (h *HLTV) getFutureMatches() ([]model.Match, error) {
+// getFutureMatches returns the valid live and upcoming matches, the number of rows on
+// the page and the errors of all invalid rows.
+func (h *HLTV) getFutureMatches() ([]model.Match, int, []ParseError) {
 	doc := h.UpcomingPage
+	s := h.selectors().Matches
 	// Get top tier matches
-	matches := getMatchesFromMatchesPage(doc, ".liveMatch")
+	matches, live, errs := getMatchesFromMatchesPage(doc, s, s.Live, 0)
 	// Set all live matches to Live=true
 	for i, _ := range matches {
 		matches[i].Live = true
 		matches[i].Date = time.Now()
 	}
-	matches = append(matches, getMatchesFromMatchesPage(doc, ".upcomingMatch")...)
-	return matches, nil
+	upcoming, n, upcomingErrs := getMatchesFromMatchesPage(doc, s, s.Upcoming, live)
+	matches = append(matches, upcoming...)
+	return matches, live + n, append(errs, upcomingErrs...)
 }
 
 // getMatchesFromMatchesPage returns a []Match slice containing matches parsed from the upcoming matches
-// page. There are two categories as of now, live and upcoming, specified in the matchType string
-func getMatchesFromMatchesPage(doc *goquery.Document, matchType string) []model.Match {
+// page. There are two categories as of now, live and upcoming, specified in the matchType string.
+// Row indices of errors start at offset. Live matches have no date.
+func getMatchesFromMatchesPage(doc *goquery.Document, s MatchesSelectors, matchType string, offset int) ([]model.Match, int, []ParseError) {
 	matches := make([]model.Match, 0)
-	doc.Find(matchType).Each(func(i int, selection *goquery.Selection) {
-		matchHref, _ := selection.Find("a.match").First().Attr("href")
-		matchID, _ := strconv.Atoi(strings.Split(matchHref, "/")[2])
-		timeRaw, exists := selection.Find(".matchTime").First().Attr("data-unix")
+	errs := make([]ParseError, 0)
+	rows := doc.Find(matchType)
+	rows.Each(func(i int, selection *goquery.Selection) {
+		p := &rowParser{page: "matches", row: offset + i}
+		matchHref, _ := selection.Find(s.Link).First().Attr("href")
+		matchID := p.matchID(matchHref)
 		date := time.Time{}
-		if exists {
-			matchTime, _ := strconv.ParseInt(timeRaw[:len(timeRaw)-3], 10, 64)
-			date = time.Unix(matchTime, 0)
+		if matchType != s.Live {
+			timeRaw, exists := selection.Find(s.Time).First().Attr(s.TimeAttr)
+			date = p.unixMillis("date", timeRaw, exists)
 		}
 
-		event := selection.Find(".matchEventName").First().Text()
+		event := selection.Find(s.Event).First().Text()
 		eventID, _ := strconv.Atoi(
-			strings.Split(PopSlashSource(selection.Find("img.matchEventLogo")), ".")[0])
-		eventLogo, _ := selection.Find(".matchEventLogo").First().Attr("src")
+			strings.Split(PopSlashSource(selection.Find(s.EventLogo)), ".")[0])
+		eventLogo, _ := selection.Find(s.EventLogo).First().Attr("src")
+
+		format := selection.Find(s.Format).First().Text()
 
-		format := selection.Find(".matchMeta").First().Text()
+		// teams are not known yet for some matches, in which case the IDs are missing
+		team1 := selection.Find(s.TeamName).First().Text()
+		team1IDStr, _ := selection.Attr(s.Team1Attr)
+		team1ID := p.optionalID("team1", team1IDStr)
 
-		team1 := selection.Find(".matchTeamName").First().Text()
-		team1IDStr, _ := selection.Attr("team1")
-		team1ID, _ := strconv.Atoi(team1IDStr)
+		team2 := selection.Find(s.TeamName).Last().Text()
+		team2IDStr, _ := selection.Attr(s.Team2Attr)
+		team2ID := p.optionalID("team2", team2IDStr)
 
-		team2 := selection.Find(".matchTeamName").Last().Text()
-		team2IDStr, _ := selection.Attr("team2")
-		team2ID, _ := strconv.Atoi(team2IDStr)
+		if len(p.errs) > 0 {
+			errs = append(errs, p.errs...)
+			return
+		}
 
 		match := model.Match{
 			ID: matchID,
@@ -188,13 +378,13 @@ func getMatchesFromMatchesPage(doc *goquery.Document, matchType string) []model.
 
 		matches = append(matches, match)
 	})
-	return matches
+	return matches, rows.Length(), errs
 }
 
 // getDocument performs a GET-Query to the given URL, and creates a goquery-Document from its response.
-func getDocument(url string) (*goquery.Document, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+// The request is aborted when ctx is cancelled.
+func getDocument(ctx context.Context, client *http.Client, url string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -205,13 +395,12 @@ func getDocument(url string) (*goquery.Document, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
 		return nil, errors.New(res.Status)
 	}
 
-	defer res.Body.Close()
-
 	doc, err := goquery.NewDocumentFromReader(res.Body)
 	if err != nil {
 		return nil, err
@@ -219,3 +408,13 @@ func getDocument(url string) (*goquery.Document, error) {
 
 	return doc, nil
 }
+
+// readDocument creates a goquery-Document from a saved HTML file.
+func readDocument(path string) (*goquery.Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return goquery.NewDocumentFromReader(f)
+}
@@ -0,0 +1,149 @@
+package csgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readTestPages returns an HLTV instance with the saved pages in testdata.
+func readTestPages(t *testing.T, upcoming, results string) *HLTV {
+	h := &HLTV{}
+	assert.Nil(t, h.ReadPages(filepath.Join("testdata", upcoming), filepath.Join("testdata", results)))
+	return h
+}
+
+func TestHLTV_Parse(t *testing.T) {
+	h := readTestPages(t, "matches.html", "results.html")
+	past, future, err := h.Parse()
+	assert.Nil(t, err)
+
+	assert.Len(t, past, 3)
+	// oldest result first
+	assert.Equal(t, 2352763, past[0].ID)
+	last := past[2]
+	assert.Equal(t, 2352765, last.ID)
+	assert.Equal(t, "OG", last.Team1.Name)
+	assert.Equal(t, "Astralis", last.Team2.Name)
+	assert.Equal(t, "OG", last.Result.Winner)
+	assert.Equal(t, "2-1", last.Result.Score)
+	assert.Equal(t, "IEM Fall 2021 Europe", last.Event.Name)
+	assert.Equal(t, time.Unix(1636740000, 0), last.Date)
+
+	assert.Len(t, future, 3)
+	assert.True(t, future[0].Live)
+	assert.Equal(t, 2352800, future[0].ID)
+	next := future[1]
+	assert.Equal(t, 2352801, next.ID)
+	assert.Equal(t, "G2", next.Team1.Name)
+	assert.Equal(t, 5995, next.Team1.ID)
+	assert.Equal(t, "Vitality", next.Team2.Name)
+	assert.Equal(t, 9565, next.Team2.ID)
+	assert.Equal(t, 6137, next.Event.ID)
+	assert.Equal(t, "bo3", next.Format)
+	assert.Equal(t, time.Unix(1636826400, 0), next.Date)
+}
+
+func TestLoadSelectors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "selectors.json")
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	// missing fields keep their default
+	s, err := LoadSelectors(write(`{"version": 1, "results": {"row": ".result-container"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, ".result-container", s.Results.Row)
+	assert.Equal(t, DefaultSelectors().Results.Winner, s.Results.Winner)
+	assert.Equal(t, DefaultSelectors().Matches, s.Matches)
+
+	_, err = LoadSelectors(write(`{"results": {"row": ".result-container"}}`))
+	assert.NotNil(t, err)
+	_, err = LoadSelectors(write(`{"version": 1, "matches": {"link": "a["}}`))
+	assert.NotNil(t, err)
+	_, err = LoadSelectors(write(`{"version": 1, "matches": {"team1_attr": ""}}`))
+	assert.NotNil(t, err)
+
+	// a markup change can be fixed by configuring the selectors
+	h := readTestPages(t, "matches.html", "drift/results_winners.html")
+	h.Selectors, err = LoadSelectors(write(`{"version": 1, "results": {"winner": ".team-winner"}}`))
+	assert.Nil(t, err)
+	past, _, err := h.Parse()
+	assert.Nil(t, err)
+	if assert.Len(t, past, 3) {
+		assert.Equal(t, "OG", past[2].Result.Winner)
+	}
+}
+
+// Tests if pages with a changed markup are refused, instead of returning partially empty
+// matches
+func TestHLTV_LayoutChanged(t *testing.T) {
+	tests := []struct {
+		upcoming, results string
+		page, check       string
+	}{
+		{"matches.html", "drift/results_renamed_rows.html", "results", "number of results"},
+		{"matches.html", "drift/results_match_links.html", "results", "match IDs"},
+		{"matches.html", "drift/results_dates.html", "results", "dates"},
+		{"matches.html", "drift/results_winners.html", "results", "winners"},
+		{"drift/matches_match_links.html", "results.html", "matches", "match IDs"},
+	}
+	for _, test := range tests {
+		h := readTestPages(t, test.upcoming, test.results)
+		past, future, err := h.Parse()
+		assert.Nil(t, past)
+		assert.Nil(t, future)
+		if assert.IsType(t, &LayoutChangedError{}, err, test.results) {
+			assert.Equal(t, test.page, err.(*LayoutChangedError).Page)
+			assert.Equal(t, test.check, err.(*LayoutChangedError).Check)
+		}
+
+		// the checks can be disabled
+		h.Layout.Disabled = true
+		_, _, err = h.Parse()
+		assert.Nil(t, err)
+	}
+}
+
+// Tests if malformed rows are skipped and reported, without panicking
+func TestHLTV_ParseDiagnostics(t *testing.T) {
+	h := readTestPages(t, "malformed/matches.html", "malformed/results.html")
+	h.Layout.Disabled = true
+	past, future, diag, err := h.ParseWithDiagnostics()
+	assert.Nil(t, err)
+
+	assert.Len(t, past, 1)
+	assert.Equal(t, 2352765, past[0].ID)
+	// matches without teams are kept
+	assert.Len(t, future, 2)
+	assert.Equal(t, 2352803, future[1].ID)
+
+	errs := make(map[string]ParseError)
+	for _, e := range diag {
+		errs[fmt.Sprintf("%s/%d/%s", e.Page, e.Row, e.Field)] = e
+	}
+	assert.Len(t, errs, len(diag))
+	assert.Contains(t, errs, "results/1/id")
+	assert.Equal(t, "12", errs["results/1/date"].Raw)
+	assert.Contains(t, errs, "results/2/date")
+	assert.Contains(t, errs, "results/2/winner")
+	assert.Equal(t, "W", errs["results/2/score_won"].Raw)
+	assert.Equal(t, "FF", errs["results/2/score_lost"].Raw)
+	assert.Contains(t, errs, "matches/1/id")
+	assert.Contains(t, errs, "matches/1/date")
+	assert.Equal(t, "TBD", errs["matches/1/team1"].Raw)
+	assert.Len(t, errs, 9)
+
+	// with the layout checks enabled, the batch is refused
+	h.Layout.Disabled = false
+	past, _, diag, err = h.ParseWithDiagnostics()
+	assert.IsType(t, &LayoutChangedError{}, err)
+	assert.Nil(t, past)
+	assert.NotEmpty(t, diag)
+}
@@ -0,0 +1,100 @@
+package csgo
+
+import (
+	"fmt"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// DefaultMinValidRatio is the default of LayoutChecks.MinValidRatio.
+const DefaultMinValidRatio = 0.9
+
+// LayoutChangedError is returned by HLTV if a parsed page does not meet the structural
+// expectations of the scraper, which usually means that the HLTV markup has changed. The
+// matches of the page are discarded, instead of publishing partially empty matches.
+type LayoutChangedError struct {
+	// Page is either "results" or "matches"
+	Page string
+	// Check is the expectation that failed, e.g. "match IDs"
+	Check string
+	// Valid and Total are the number of valid entries, and the number of all entries
+	Valid, Total int
+}
+
+func (e *LayoutChangedError) Error() string {
+	return fmt.Sprintf("HLTV layout changed: %s page failed check %q (%d of %d valid)", e.Page, e.Check, e.Valid, e.Total)
+}
+
+// LayoutChecks configures the structural expectations that every page parsed by HLTV must
+// meet, see LayoutChangedError.
+type LayoutChecks struct {
+	// MinResults is the minimum number of results on the results page. Defaults to 1.
+	MinResults int
+	// MinValidRatio is the minimum fraction of rows with a valid match ID, a valid date
+	// and, on the results page, a winner that is one of the two teams. It is also the
+	// minimum fraction of rows that must be parsed without any error, see ParseError.
+	// Defaults to DefaultMinValidRatio.
+	MinValidRatio float64
+	// Disabled turns off all checks.
+	Disabled bool
+}
+
+func (c LayoutChecks) minResults() int {
+	if c.MinResults == 0 {
+		return 1
+	}
+	return c.MinResults
+}
+
+func (c LayoutChecks) minValidRatio() float64 {
+	if c.MinValidRatio == 0 {
+		return DefaultMinValidRatio
+	}
+	return c.MinValidRatio
+}
+
+// check returns a LayoutChangedError if fewer than the required fraction of valid out of
+// total entries are valid.
+func (c LayoutChecks) check(page, check string, valid, total int) error {
+	if float64(valid) < c.minValidRatio()*float64(total) {
+		return &LayoutChangedError{Page: page, Check: check, Valid: valid, Total: total}
+	}
+	return nil
+}
+
+// checkResults checks the matches parsed from the results page, which contains rows
+// entries. errs are the errors of the rows that could not be parsed.
+func (c LayoutChecks) checkResults(rows int, past []model.Match, errs []ParseError) error {
+	if c.Disabled {
+		return nil
+	}
+	if rows < c.minResults() {
+		return &LayoutChangedError{Page: "results", Check: "number of results", Valid: rows, Total: c.minResults()}
+	}
+	return c.checkRows("results", rows, past, errs, "id", "date", "winner")
+}
+
+// checkMatches checks the matches parsed from a page, which contains rows entries.
+func (c LayoutChecks) checkMatches(page string, rows int, matches []model.Match, errs []ParseError) error {
+	if c.Disabled {
+		return nil
+	}
+	return c.checkRows(page, rows, matches, errs, "id", "date")
+}
+
+// checks maps the fields of a ParseError to the name of the corresponding check.
+var checks = map[string]string{
+	"id":     "match IDs",
+	"date":   "dates",
+	"winner": "winners",
+}
+
+// checkRows checks the given fields of every row, and that enough rows have been parsed.
+func (c LayoutChecks) checkRows(page string, rows int, matches []model.Match, errs []ParseError, fields ...string) error {
+	for _, field := range fields {
+		if err := c.check(page, checks[field], rows-countErrors(errs, field), rows); err != nil {
+			return err
+		}
+	}
+	return c.check(page, "parsed "+page, len(matches), rows)
+}
@@ -0,0 +1,143 @@
+// Package merkle implements a Merkle tree over key-value pairs, with inclusion proofs.
+// The oracle uses it to commit to every result it has ever published, so that results
+// which have rotated out of the buffer can still be verified against an on-chain root.
+//
+// Leaves are sorted by key. A leaf hash is SHA-256(0x00 || len(key) || key || value),
+// where len(key) is a single byte, and an inner node hash is SHA-256(0x01 || left || right).
+// If a level has an odd number of nodes, the last node is promoted to the next level.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// HashLength is the length of a hash in bytes.
+const HashLength = sha256.Size
+
+// MaxKeyLength is the maximum length of a key in bytes.
+const MaxKeyLength = 255
+
+// Step is a single step of an inclusion proof.
+type Step struct {
+	// Sibling is the hash of the sibling node
+	Sibling []byte `json:"sibling"`
+	// Left is true if the sibling is the left child
+	Left bool `json:"left"`
+}
+
+// Proof proves the inclusion of a key-value pair in a tree.
+type Proof struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	Steps []Step `json:"steps"`
+}
+
+// Tree is an immutable Merkle tree over key-value pairs.
+type Tree struct {
+	keys   []string
+	values map[string][]byte
+	// levels[0] contains the leaf hashes, the last level contains the root
+	levels [][][]byte
+}
+
+// New builds a tree over the given key-value pairs. Returns an error if a key exceeds
+// MaxKeyLength.
+func New(entries map[string][]byte) (*Tree, error) {
+	t := &Tree{keys: make([]string, 0, len(entries)), values: make(map[string][]byte, len(entries))}
+	for k, v := range entries {
+		if len(k) > MaxKeyLength {
+			return nil, fmt.Errorf("key has %d bytes, limit is %d", len(k), MaxKeyLength)
+		}
+		t.keys = append(t.keys, k)
+		t.values[k] = v
+	}
+	sort.Strings(t.keys)
+	level := make([][]byte, len(t.keys))
+	for i, k := range t.keys {
+		level[i] = LeafHash(k, t.values[k])
+	}
+	t.levels = [][][]byte{level}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, NodeHash(level[i], level[i+1]))
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t, nil
+}
+
+// Len returns the number of leaves.
+func (t *Tree) Len() int {
+	return len(t.keys)
+}
+
+// Root returns the root hash. The root of an empty tree is the hash of no data.
+func (t *Tree) Root() []byte {
+	if len(t.keys) == 0 {
+		h := sha256.Sum256(nil)
+		return h[:]
+	}
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Proof returns the inclusion proof of a key.
+func (t *Tree) Proof(key string) (*Proof, error) {
+	i := sort.SearchStrings(t.keys, key)
+	if i == len(t.keys) || t.keys[i] != key {
+		return nil, errors.New("key not in tree")
+	}
+	p := &Proof{Key: key, Value: t.values[key], Steps: []Step{}}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := i ^ 1
+		if sibling < len(level) {
+			p.Steps = append(p.Steps, Step{Sibling: level[sibling], Left: sibling < i})
+		}
+		i /= 2
+	}
+	return p, nil
+}
+
+// Verify returns true if the proof shows that its key-value pair is included in the tree
+// with the given root.
+func (p *Proof) Verify(root []byte) bool {
+	if len(p.Key) > MaxKeyLength {
+		return false
+	}
+	h := LeafHash(p.Key, p.Value)
+	for _, s := range p.Steps {
+		if s.Left {
+			h = NodeHash(s.Sibling, h)
+		} else {
+			h = NodeHash(h, s.Sibling)
+		}
+	}
+	return bytes.Equal(h, root)
+}
+
+// LeafHash returns the hash of a leaf.
+func LeafHash(key string, value []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00, byte(len(key))})
+	h.Write([]byte(key))
+	h.Write(value)
+	return h.Sum(nil)
+}
+
+// NodeHash returns the hash of an inner node.
+func NodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
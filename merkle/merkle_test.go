@@ -0,0 +1,37 @@
+package merkle
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTree_Proofs(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		entries := make(map[string][]byte, n)
+		for i := 0; i < n; i++ {
+			entries[fmt.Sprint(i)] = []byte(fmt.Sprintf("winner %d", i))
+		}
+		tree, err := New(entries)
+		assert.Nil(t, err)
+		root := tree.Root()
+		for k, v := range entries {
+			p, err := tree.Proof(k)
+			assert.Nil(t, err)
+			assert.Equal(t, v, p.Value)
+			assert.True(t, p.Verify(root), "n=%d key=%s", n, k)
+
+			// tampered values must not verify
+			p.Value = []byte("tampered")
+			assert.False(t, p.Verify(root))
+		}
+	}
+}
+
+func TestTree_Empty(t *testing.T) {
+	tree, err := New(nil)
+	assert.Nil(t, err)
+	assert.Len(t, tree.Root(), HashLength)
+	_, err = tree.Proof("1")
+	assert.Error(t, err)
+}
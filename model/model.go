@@ -25,6 +25,12 @@ type Match struct {
 	Format string
 	Result Result
 	Live   bool
+	// Maps contains the maps of the match, in the order they are played. Only set if
+	// map details have been fetched, see csgo.DetailFetcher.
+	Maps []Map `json:",omitempty"`
+	// Bans contains the maps removed in the map veto, in the order they were removed. Only
+	// set if map details have been fetched.
+	Bans []Ban `json:",omitempty"`
 }
 
 type Result struct {
@@ -33,3 +39,25 @@ type Result struct {
 	// Numbered score (e.g. "1-0", "3-2"). Winner's score is always listed first.
 	Score string
 }
+
+type Map struct {
+	// Map name e.g. "Inferno"
+	Name string
+	// Rounds won by each team. Both are zero if the map has not been played yet.
+	Team1Rounds int
+	Team2Rounds int
+	// Name of the team that picked the map. Empty for the decider.
+	PickedBy string
+	// Step of the map veto in which the map was picked or left over, starting at 1. Zero
+	// if the veto is unknown.
+	VetoStep int
+}
+
+type Ban struct {
+	// Map name e.g. "Vertigo"
+	Map string
+	// Name of the team that removed the map
+	BannedBy string
+	// Step of the map veto in which the map was removed, starting at 1
+	VetoStep int
+}
@@ -2,20 +2,65 @@ package csgo
 
 import (
 	"context"
+	"io"
 	"log"
+	"os"
 	"sync"
 	"time"
 
-	siam "github.com/m2q/algo-siam"
 	"github.com/m2q/algo-siam/client"
+	"github.com/m2q/siam-cs/archive"
+	"github.com/m2q/siam-cs/clock"
+	"github.com/m2q/siam-cs/codec"
+	"github.com/m2q/siam-cs/merkle"
+	"github.com/m2q/siam-cs/model"
 )
 
 // Oracle fetches, compares, and pushes data to the Blockchain
 type Oracle struct {
 	cfg          *OracleConfig
-	buffer       *siam.AlgorandBuffer
+	publisher    Publisher
 	cancelOracle context.CancelFunc
 	wgExit       *sync.WaitGroup
+
+	// mu guards the fields below, which are accessed outside the serving goroutine
+	mu     sync.Mutex
+	report *VerificationReport
+
+	// pending and final track results of past matches, see FinalityWindow
+	pending map[int]*observation
+	final   map[int]model.Result
+
+	// published contains the matches last published, corrections the detected changes
+	// of published results, see Correction
+	published   map[string]model.Match
+	corrections map[int]*Correction
+
+	// history contains every result published so far, tree the Merkle tree over it
+	history map[string]HistoryEntry
+	tree    *merkle.Tree
+	// root is the history root last read from or written to the Publisher
+	root string
+
+	// err is the error that caused the serving goroutine to exit
+	err error
+
+	// announced contains the last reported version of every live or upcoming match, see
+	// completeMatches
+	announced map[int]model.Match
+
+	// lastFetched is the number of matches of the last fetch that was published
+	lastFetched int
+	// prefetched is the fetch made during reconciliation, which is used by the first cycle
+	prefetched *fetchResult
+	// pinsRestored is true once the pinned matches have been restored, see restorePinned
+	pinsRestored bool
+}
+
+// fetchResult is the result of a single fetch of the PrimaryAPI.
+type fetchResult struct {
+	past, future []model.Match
+	diag         []ParseError
 }
 
 // OracleConfig defines the oracles behavior
@@ -26,14 +71,109 @@ type OracleConfig struct {
 
 	// VerificationAPIs is an optional list of verification APIs used to verify the correctness
 	// of the PrimaryAPI. If the data proposed from the PrimaryAPI does not match data from each
-	// of verification APIs, the data is not written to the blockchain.
+	// of verification APIs, the data is not written to the blockchain. See Quorum.
 	VerificationAPIs []API
 
 	// MaxVerifyTime does not need to be set if VerificationAPIs is nil. If the VerificationAPIs
 	// do not agree with the PrimaryAPI's proposal within MaxVerifyTime, then the proposed data
-	// gets discarded
+	// gets discarded. A MaxVerifyTime of zero waits for the VerificationAPIs indefinitely.
 	MaxVerifyTime time.Duration
 
+	// Quorum decides per match ID whether the proposal of the PrimaryAPI is accepted, based
+	// on the votes of the VerificationAPIs. Defaults to Unanimous.
+	Quorum Quorum
+
+	// OnVerification is an optional hook that receives the report of every verification
+	// round, e.g. for auditing. It is called from the serving goroutine.
+	OnVerification func(*VerificationReport)
+
+	// Finality delays the publication of results until they are considered final. By
+	// default, results are published as soon as they are fetched.
+	Finality FinalityWindow
+
+	// ApproveCorrections requires corrections of published results to be approved via
+	// ApproveCorrection before they are published. Until then, the old value is kept.
+	ApproveCorrections bool
+
+	// CorrectionLog is an optional append-only log that records every Correction.
+	CorrectionLog *CorrectionLog
+
+	// OnCorrection is an optional hook that receives every Correction, when it is detected
+	// and when it is approved.
+	OnCorrection func(Correction)
+
+	// Safety detects anomalous fetches and refuses to publish them, keeping the previous
+	// state on the buffer instead.
+	Safety SafetyGuard
+
+	// MaxChurn caps the number of match keys that may be added, changed or deleted in a
+	// single cycle. The most important changes are applied first, see LimitChurn. The
+	// schema metadata and the history root are exempt, and their slots are kept free.
+	// Zero means no limit.
+	MaxChurn int
+
+	// DryRun disables publishing. Instead, the changes that would be made to the Publisher
+	// are written to DryRunOutput, both human-readable and as JSON. A dry run has no other
+	// side effects: nothing is written to the Archive or the CorrectionLog, and the state
+	// tracked across cycles (e.g. the FinalityWindow) is not advanced.
+	DryRun bool
+
+	// DryRunOutput receives the diffs computed in DryRun mode. Defaults to os.Stdout.
+	DryRunOutput io.Writer
+
+	// SelectionPolicy decides which matches occupy the slots of the buffer. Defaults to
+	// RecentResultsFirst.
+	SelectionPolicy SelectionPolicy
+
+	// Encoding determines how matches are represented as values on the buffer. Defaults
+	// to WinnerNameEncoding.
+	Encoding ValueEncoding
+
+	// KeySchema determines the keys of the buffer. Schemas of version 1 or higher prefix
+	// every key with a namespace, including the reserved keys, and publish a metadata key
+	// (codec.MetaKey) describing the schema, which occupies one slot of the buffer.
+	// Defaults to bare match IDs.
+	KeySchema codec.KeySchema
+
+	// CommitHistory maintains a Merkle tree over every result ever published, and writes
+	// its root to codec.RootKey every cycle, which occupies one slot of the buffer.
+	// Inclusion proofs of past results are available via Proof. Requires an Archive, from
+	// which the history is restored after a restart, see ErrNoArchive.
+	CommitHistory bool
+
+	// Archive optionally stores every fetched match version and every published value. If
+	// CommitHistory is set, the history is restored from the Archive after a restart.
+	Archive *archive.Archive
+
+	// FillFromArchive passes archived results that are older than the fetched results to
+	// the SelectionPolicy, e.g. results stored by Backfill. This way, slots that cannot
+	// be filled with fetched matches are filled with older results. Requires an Archive.
+	// The VerificationAPIs usually do not report archived results, so they are held back
+	// unless the Quorum accepts them without agreeing votes.
+	FillFromArchive bool
+
+	// RefuseForeignData prevents the Oracle from starting if the buffer contains keys that
+	// it did not write, see Reconcile. By default, foreign keys are only reported, and
+	// overwritten or deleted by the first write.
+	RefuseForeignData bool
+
+	// OnReconciliation is an optional hook that receives the report of the reconciliation
+	// at startup.
+	OnReconciliation func(*ReconciliationReport)
+
+	// Details optionally fetches the maps of every match that enters the buffer, or whose
+	// published value changes. Fetched maps are attached to the matches of the following
+	// cycles, see model.Match.Maps. The DetailFetcher is run by Serve.
+	Details *DetailFetcher
+
+	// Watchlist pins matches on the buffer, regardless of the SelectionPolicy. If the
+	// Watchlist has a Source, it is refreshed every cycle.
+	Watchlist *Watchlist
+
+	// Clock is the source of time for the Oracle, e.g. for PastMatchesTTL and the
+	// RefreshInterval. Defaults to clock.Real.
+	Clock clock.Clock
+
 	// RefreshInterval is the pause between two API fetch commands.
 	// If the API accesses a rate-limited resource, then set RefreshInterval high enough
 	// as to not trigger a rate-limit or blacklist event.
@@ -41,29 +181,55 @@ type OracleConfig struct {
 }
 
 // NewOracle creates and initializes an Oracle struct. Requires an API to fetch and
-// collect data, as well as a siam.AlgorandBuffer, in order to publish changes to the
-// blockchain.
-func NewOracle(b *siam.AlgorandBuffer, cfg *OracleConfig) *Oracle {
-	return &Oracle{cfg: cfg, buffer: b}
+// collect data, as well as a Publisher (e.g. siam.AlgorandBuffer), in order to publish
+// changes to the blockchain.
+func NewOracle(p Publisher, cfg *OracleConfig) *Oracle {
+	return &Oracle{cfg: cfg, publisher: p}
 }
 
-// Serve spawns a cancelable goroutine that aims to keep the AlgorandBuffer
+// Serve spawns a cancelable goroutine that aims to keep the Publisher
 // in a desired state. See ConstructDesiredState.
 //
+// Before the first write, the current contents of the Publisher are reconciled, see
+// Reconcile. If RefuseForeignData is set and foreign data is found, the goroutine exits
+// and Err returns a ForeignDataError.
+//
 // Any goroutines spawned by the Oracle can be cancelled anytime via Stop.
 func (o *Oracle) Serve() {
 	var wg sync.WaitGroup
 	ctx, cancel := context.WithCancel(context.Background())
+	if o.cfg.Details != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.cfg.Details.Run(ctx)
+		}()
+	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		reconciled := false
 		// serving loop
 		for ctx.Err() == nil {
-			o.serve(ctx)
+			if !reconciled {
+				err := o.reconcile(ctx)
+				if _, foreign := err.(*ForeignDataError); foreign {
+					log.Print(err)
+					o.setErr(err)
+					return
+				}
+				if err != nil {
+					log.Printf("reconciliation failed, retrying: %v", err)
+				}
+				reconciled = err == nil
+			}
+			if reconciled {
+				o.serve(ctx)
+			}
 			select {
 			case <-ctx.Done():
 				return
-			case <-time.After(o.cfg.RefreshInterval):
+			case <-o.clock().After(o.cfg.RefreshInterval):
 				continue
 			}
 		}
@@ -72,24 +238,251 @@ func (o *Oracle) Serve() {
 	o.wgExit = &wg
 }
 
-// serve attempts to bring the AlgorandBuffer in a desired state. It returns
+// Err returns the error that caused the serving goroutine to exit, if any.
+func (o *Oracle) Err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.err
+}
+
+// setErr stores the error that caused the serving goroutine to exit.
+func (o *Oracle) setErr(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.err = err
+}
+
+// clock returns the configured Clock, or clock.Real if none is set.
+func (o *Oracle) clock() clock.Clock {
+	if o.cfg.Clock == nil {
+		return clock.Real{}
+	}
+	return o.cfg.Clock
+}
+
+// format returns the Format of the published state.
+func (o *Oracle) format() Format {
+	return Format{Keys: o.cfg.KeySchema, Encoding: o.cfg.Encoding}
+}
+
+// policy returns the configured SelectionPolicy, or RecentResultsFirst if none is set.
+// If a Watchlist is configured, its matches are pinned on top of the policy. In the first
+// cycle, matches pinned before a restart are restored from current, see restorePinned.
+func (o *Oracle) policy(current map[string]string) SelectionPolicy {
+	var p SelectionPolicy = RecentResultsFirst{}
+	if o.cfg.SelectionPolicy != nil {
+		p = o.cfg.SelectionPolicy
+	}
+	if o.cfg.Watchlist != nil {
+		w := o.cfg.Watchlist
+		if o.cfg.DryRun {
+			// a dry run must not remember the reported pinned matches
+			w = w.clone()
+		}
+		if !o.pinsRestored {
+			o.restorePinned(w, current)
+			o.pinsRestored = !o.cfg.DryRun
+		}
+		p = WatchlistPinned{Watchlist: w, Fallback: p}
+	}
+	return p
+}
+
+// restorePinned pins the matches of the Watchlist w that are on the buffer, with their
+// latest version from the Archive. Pinned matches are only remembered in memory, so this
+// keeps them on the buffer after a restart, even if they are no longer reported.
+func (o *Oracle) restorePinned(w *Watchlist, current map[string]string) {
+	if o.cfg.Archive == nil {
+		return
+	}
+	for _, e := range w.Entries() {
+		if _, ok := current[o.format().Key(e.ID)]; !ok {
+			continue
+		}
+		if entry, ok := o.cfg.Archive.Get(e.ID); ok {
+			w.restore(entry.Latest(), o.clock().Now())
+		}
+	}
+}
+
+// serve attempts to bring the Publisher in a desired state. It returns
 // a minimum time that the caller should wait before executing serve again.
 func (o *Oracle) serve(ctx context.Context) {
 	// fetch CSGO matches
-	past, future, err := o.cfg.PrimaryAPI.Fetch()
+	past, future, diag, err := o.fetch(ctx)
 	if err != nil {
 		log.Print(err)
 		return
 	}
-	desired := ConstructDesiredState(past, future, client.GlobalBytes)
-	err = o.buffer.AchieveDesiredState(ctx, desired)
+	for _, e := range diag {
+		log.Printf("skipped row: %v", e)
+	}
+	if err = o.cfg.Safety.checkParse(diag); err != nil {
+		log.Print(err)
+		return
+	}
+	fetched := len(past) + len(future)
+	if err = o.cfg.Safety.checkFetch(fetched, o.lastFetched); err != nil {
+		log.Print(err)
+		return
+	}
+	if !o.cfg.DryRun {
+		o.recordAnnounced(past, future)
+	}
+	past, future = o.completeMatches(past, future)
+	if o.cfg.Details != nil {
+		past, future = o.cfg.Details.Annotate(past), o.cfg.Details.Annotate(future)
+	}
+	if o.cfg.Archive != nil && !o.cfg.DryRun {
+		for _, m := range [][]model.Match{past, future} {
+			if err := o.cfg.Archive.RecordFetch(o.clock().Now(), m...); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+
+	if o.cfg.Watchlist != nil && o.cfg.Watchlist.Source != "" && !o.cfg.DryRun {
+		if err := o.cfg.Watchlist.Refresh(ctx); err != nil {
+			log.Printf("keeping previous watchlist: %v", err)
+		}
+	}
+
+	current, err := o.publisher.GetBuffer(ctx)
 	if err != nil {
 		log.Print(err)
+		return
+	}
+	o.setRoot(current)
+	if o.cfg.Finality.enabled() {
+		past = o.applyFinality(past, current, o.clock().Now())
+	}
+	if o.cfg.FillFromArchive && o.cfg.Archive != nil {
+		past = o.fillFromArchive(past, future)
+	}
+	desired, issues := BuildDesiredState(o.policy(current), o.format(), past, future, client.GlobalBytes-o.reserved(), o.clock())
+	for _, issue := range issues {
+		log.Print(issue)
+	}
+	// cross-check proposal with verification APIs
+	if len(o.cfg.VerificationAPIs) > 0 {
+		desired, err = o.verify(ctx, current, desired)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+	}
+	desired = o.reviewCorrections(current, desired, past, o.clock().Now())
+	if err = o.cfg.Safety.checkState(o.format().matchEntries(current), desired); err != nil {
+		log.Print(err)
+		return
+	}
+	// publish schema metadata
+	for k, v := range o.format().Metadata() {
+		desired[k] = v
+	}
+	if root, ok := current[o.rootKey()]; ok && o.cfg.CommitHistory {
+		// keep the previous root until the history is updated below
+		desired[o.rootKey()] = root
+	}
+	index := IndexMatches(o.format(), past, future)
+	if o.cfg.MaxChurn > 0 {
+		entries := o.format().matchEntries(desired)
+		limited := LimitChurn(o.format().matchEntries(current), entries, index, o.cfg.MaxChurn, client.GlobalBytes-o.reserved())
+		// reserved keys are not limited, see MaxChurn
+		for k, v := range desired {
+			if _, ok := entries[k]; !ok {
+				limited[k] = v
+			}
+		}
+		desired = limited
+	}
+	// commit to every result published so far
+	var history map[string]HistoryEntry
+	var tree *merkle.Tree
+	if o.cfg.CommitHistory {
+		history, tree, err = o.updateHistory(desired, index, o.clock().Now())
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		desired[o.rootKey()] = string(tree.Root())
+	}
+	if o.cfg.DryRun {
+		o.printDiff(ComputeDiff(current, desired, index))
+		return
+	}
+	err = o.publisher.AchieveDesiredState(ctx, desired)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	// only a fetch that passed every guard is the baseline of the next checkFetch
+	o.lastFetched = fetched
+	o.setRoot(desired)
+	o.recordPublished(desired, index)
+	d := ComputeDiff(current, desired, index)
+	if o.cfg.Archive != nil {
+		o.archivePublished(d)
+	}
+	if o.cfg.Details != nil {
+		// forget the maps of matches that left the buffer
+		ids := make([]int, 0, len(desired))
+		for k := range desired {
+			if m, ok := index[k]; ok {
+				ids = append(ids, m.ID)
+			}
+		}
+		o.cfg.Details.Retain(ids)
+		for _, e := range append(d.Add, d.Update...) {
+			if e.Match != nil {
+				o.cfg.Details.Enqueue(*e.Match)
+			}
+		}
+	}
+	if o.cfg.CommitHistory {
+		o.commitHistory(history, tree)
+	}
+}
+
+// fetch fetches the PrimaryAPI. If it implements DiagnosticAPI, the rows it could not
+// parse are returned as well. If the PrimaryAPI was fetched during reconciliation, that
+// fetch is returned once instead.
+func (o *Oracle) fetch(ctx context.Context) (past, future []model.Match, diag []ParseError, err error) {
+	if f := o.prefetched; f != nil {
+		o.prefetched = nil
+		return f.past, f.future, f.diag, nil
+	}
+	if api, ok := o.cfg.PrimaryAPI.(DiagnosticAPI); ok {
+		return api.FetchWithDiagnostics(ctx)
+	}
+	past, future, err = o.cfg.PrimaryAPI.Fetch(ctx)
+	return past, future, nil, err
+}
+
+// RunOnce performs a single cycle of the serving loop and blocks until it has finished.
+// This is useful in DryRun mode, to inspect the changes of a single cycle.
+func (o *Oracle) RunOnce(ctx context.Context) {
+	o.serve(ctx)
+}
+
+// printDiff writes the diff to DryRunOutput.
+func (o *Oracle) printDiff(d *Diff) {
+	w := o.cfg.DryRunOutput
+	if w == nil {
+		w = os.Stdout
+	}
+	if err := d.WriteText(w); err != nil {
+		log.Print(err)
+		return
+	}
+	if err := d.WriteJSON(w); err != nil {
+		log.Print(err)
 	}
 }
 
-// Stop signals the Oracle to stop its goroutine and stop the siam.AlgorandBuffer
-// managing routine. Stop will block until both goroutines have exited.
+// Stop signals the Oracle to stop its goroutine and stop the Publisher
+// managing routine. In-flight API requests are cancelled. Stop will block until both
+// goroutines have exited.
 func (o *Oracle) Stop() {
 	if o.cancelOracle != nil {
 		o.cancelOracle()
@@ -1,14 +1,21 @@
 package csgo
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	siam "github.com/m2q/algo-siam"
 	"github.com/m2q/algo-siam/client"
+	"github.com/m2q/siam-cs/archive"
+	"github.com/m2q/siam-cs/clock"
+	"github.com/m2q/siam-cs/codec"
 	"github.com/m2q/siam-cs/generator"
 	"github.com/m2q/siam-cs/model"
 	"github.com/stretchr/testify/assert"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -32,6 +39,14 @@ func setupOracleMockedAPI(refresh time.Duration) (*Oracle, *siam.AlgorandBuffer,
 	return o, buffer, api
 }
 
+// tempArchive opens an empty Archive, which is closed at the end of the test.
+func tempArchive(t *testing.T) *archive.Archive {
+	a, err := archive.Open(filepath.Join(t.TempDir(), "archive.jsonl"))
+	assert.Nil(t, err)
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
 // setupOracleWithData creates an Oracle, Stub and AlgorandBuffer instance, and waits until
 // the Oracle has filled specified data into the AlgorandBuffer.
 func setupOracleWithData(past, future []model.Match, t *testing.T) (*Oracle, *siam.AlgorandBuffer, *StubAPI) {
@@ -40,14 +55,14 @@ func setupOracleWithData(past, future []model.Match, t *testing.T) (*Oracle, *si
 	// Set match data to stub
 	stub.SetMatches(past, future)
 	// Check if desired state is written by Oracle
-	desired := ConstructDesiredState(past, future, client.GlobalBytes)
+	desired := ConstructDesiredState(past, future, client.GlobalBytes, clock.Real{})
 	contains := buffer.ContainsWithin(desired, time.Second*5, 0)
 	assert.True(t, contains)
 	return oracle, buffer, stub
 }
 
 func containsDesiredState(b *siam.AlgorandBuffer, past []model.Match, future []model.Match, t time.Duration) bool {
-	desired := ConstructDesiredState(past, future, client.GlobalBytes)
+	desired := ConstructDesiredState(past, future, client.GlobalBytes, clock.Real{})
 	contains := b.ContainsWithin(desired, t, 0)
 	return contains
 }
@@ -70,7 +85,7 @@ func TestOracle_ProgressTime(t *testing.T) {
 	defer oracle.Stop()
 
 	// Let one game play out
-	past, future = generator.ProgressTime(past, future, 1)
+	past, future = generator.ProgressTime(past, future, 1, clock.Real{})
 	stub.SetMatches(past, future)
 
 	assert.True(t, containsDesiredState(b, past, future, time.Second*2))
@@ -102,3 +117,566 @@ func TestOracle_RespectFetchTime(t *testing.T) {
 
 	time.Sleep(time.Millisecond * 190)
 }
+
+// Tests if entries that a verification API disagrees with are held back
+func TestOracle_VerificationHoldsBack(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	oracle, b, _ := setupOracleMockedAPI(0)
+
+	// verifier disagrees on the most recent result
+	disputed := make([]model.Match, len(past))
+	copy(disputed, past)
+	disputed[len(disputed)-1].Result.Winner = "Disputed"
+	verifier := &StubAPI{}
+	verifier.SetMatches(disputed, future)
+	oracle.cfg.VerificationAPIs = []API{verifier}
+	oracle.cfg.MaxVerifyTime = time.Second
+
+	oracle.cfg.PrimaryAPI.(*StubAPI).SetMatches(past, future)
+	oracle.Serve()
+	defer oracle.Stop()
+
+	desired := ConstructDesiredState(past, future, client.GlobalBytes, clock.Real{})
+	key := strconv.Itoa(past[len(past)-1].ID)
+	delete(desired, key)
+	assert.True(t, b.ContainsWithin(desired, time.Second*2, 0))
+
+	data, err := b.GetBuffer(context.Background())
+	assert.Nil(t, err)
+	assert.NotContains(t, data, key)
+
+	report := oracle.LastVerificationReport()
+	assert.NotNil(t, report)
+	rejected := report.Rejected()
+	assert.Len(t, rejected, 1)
+	assert.Equal(t, key, rejected[0].Key)
+	assert.Equal(t, "Disputed", rejected[0].Votes[0].Value)
+}
+
+// stallingAPI is an API that ignores cancellation of its context.
+type stallingAPI struct{}
+
+func (stallingAPI) Fetch(ctx context.Context) (past, future []model.Match, err error) {
+	select {}
+}
+
+// Tests if MaxVerifyTime is enforced, even if a verification API does not honor ctx
+func TestFetchVerifications_Timeout(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	verifier := &StubAPI{}
+	verifier.SetMatches(past, future)
+
+	start := time.Now()
+	views := fetchVerifications(context.Background(), []API{verifier, stallingAPI{}}, Format{}, time.Millisecond*50, nil)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.NotNil(t, views[0])
+	assert.Nil(t, views[1])
+}
+
+// Tests if Stop interrupts an in-flight API request
+func TestOracle_StopCancelsFetch(t *testing.T) {
+	oracle, _, stub := setupOracleMockedAPI(0)
+	stub.Delay = time.Hour
+	oracle.Serve()
+
+	stopped := make(chan struct{})
+	go func() {
+		oracle.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("oracle did not stop within 1s")
+	}
+}
+
+// Tests if the Oracle keeps the previous state when the API suddenly returns no matches
+func TestOracle_RefuseEmptyFetch(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	oracle, b, stub := setupOracleWithData(past, future, t)
+	defer oracle.Stop()
+
+	stub.SetMatches([]model.Match{}, []model.Match{})
+	time.Sleep(time.Millisecond * 100)
+	assert.True(t, containsDesiredState(b, past, future, time.Second))
+}
+
+func TestSafetyGuard_CheckFetch(t *testing.T) {
+	g := SafetyGuard{MaxDropRatio: 0.5}
+	assert.Error(t, g.checkFetch(0, 0))
+	assert.NoError(t, g.checkFetch(60, 100))
+	assert.Error(t, g.checkFetch(40, 100))
+
+	current := map[string]string{"1": "", "2": "", "3": ""}
+	g = SafetyGuard{MaxDeletions: 1}
+	assert.Error(t, g.checkState(current, map[string]string{}))
+	assert.NoError(t, g.checkState(current, map[string]string{"1": "", "2": ""}))
+	assert.Error(t, g.checkState(current, map[string]string{"1": ""}))
+
+	diag := []ParseError{{Page: "results", Row: 1, Field: "id"}, {Page: "results", Row: 1, Field: "date"}}
+	g = SafetyGuard{MaxParseErrors: 1}
+	assert.NoError(t, g.checkParse(diag))
+	assert.Error(t, g.checkParse(append(diag, ParseError{Page: "matches", Row: 1, Field: "id"})))
+}
+
+// Tests if a fetch refused by a later guard does not become the baseline of MaxDropRatio
+func TestOracle_RefusedFetchBaseline(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	p := NewMemoryPublisher()
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Safety: SafetyGuard{MaxDropRatio: 0.5, MaxDeletions: 1}})
+	oracle.RunOnce(context.Background())
+	n := len(past) + len(future)
+	assert.Equal(t, n, oracle.lastFetched)
+
+	// passes the drop ratio, but deletes too many keys
+	stub.SetMatches(past[:len(past)/2], future[:len(future)/2])
+	oracle.RunOnce(context.Background())
+	oracle.RunOnce(context.Background())
+	assert.Equal(t, n, oracle.lastFetched)
+}
+
+// Tests if results are only published after they have been observed repeatedly
+func TestOracle_ApplyFinality(t *testing.T) {
+	o := NewOracle(nil, &OracleConfig{Finality: FinalityWindow{Observations: 2, Duration: time.Minute}})
+	now := time.Now()
+	won := model.Result{Winner: "G2", Score: "2-0"}
+	past := []model.Match{{ID: 1, Result: won}}
+
+	// first observation
+	assert.Equal(t, model.Result{}, o.applyFinality(past, nil, now)[0].Result)
+	assert.Equal(t, won, o.PendingResults()[1])
+	// observed twice, but not long enough
+	assert.Equal(t, model.Result{}, o.applyFinality(past, nil, now.Add(time.Second))[0].Result)
+	// final
+	assert.Equal(t, won, o.applyFinality(past, nil, now.Add(time.Minute))[0].Result)
+	assert.Empty(t, o.PendingResults())
+
+	// a changed result restarts the window, but the final result stays published
+	past[0].Result = model.Result{Winner: "OG", Score: "2-1"}
+	assert.Equal(t, won, o.applyFinality(past, nil, now.Add(time.Hour))[0].Result)
+	assert.Equal(t, "OG", o.PendingResults()[1].Winner)
+}
+
+// Tests if a restarted Oracle keeps published winners, instead of holding them back again
+func TestOracle_FinalityAfterRestart(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	NewOracle(p, &OracleConfig{PrimaryAPI: stub}).RunOnce(context.Background())
+	before, _ := p.GetBuffer(context.Background())
+
+	restarted := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Finality: FinalityWindow{Observations: 3}})
+	restarted.RunOnce(context.Background())
+	after, _ := p.GetBuffer(context.Background())
+	assert.Equal(t, before, after)
+	// only results that have not been published are pending
+	for id := range restarted.PendingResults() {
+		assert.NotContains(t, after, strconv.Itoa(id))
+	}
+}
+
+// Tests if a changed result of a published match requires approval, and is logged
+func TestOracle_ApproveCorrection(t *testing.T) {
+	path := t.TempDir() + "/corrections.jsonl"
+	corrections, err := OpenCorrectionLog(path)
+	assert.Nil(t, err)
+	defer corrections.Close()
+
+	past, future := generator.GetData(time.Now())
+	oracle, b, stub := setupOracleMockedAPI(0)
+	oracle.cfg.ApproveCorrections = true
+	oracle.cfg.CorrectionLog = corrections
+	stub.SetMatches(past, future)
+	oracle.Serve()
+	defer oracle.Stop()
+	assert.True(t, containsDesiredState(b, past, future, time.Second))
+
+	// correct the most recent result
+	corrected := make([]model.Match, len(past))
+	copy(corrected, past)
+	last := &corrected[len(corrected)-1]
+	last.Result.Winner = last.Team2.Name
+	if last.Result.Winner == past[len(past)-1].Result.Winner {
+		last.Result.Winner = last.Team1.Name
+	}
+	stub.SetMatches(corrected, future)
+
+	assert.Eventually(t, func() bool { return len(oracle.PendingCorrections()) == 1 }, time.Second, time.Millisecond)
+	assert.True(t, containsDesiredState(b, past, future, time.Second))
+
+	assert.Nil(t, oracle.ApproveCorrection(last.ID))
+	assert.True(t, containsDesiredState(b, corrected, future, time.Second))
+
+	logged, err := ReadCorrections(path)
+	assert.Nil(t, err)
+	assert.Len(t, logged, 2)
+	assert.Equal(t, CorrectionPending, logged[0].Status)
+	assert.Equal(t, CorrectionApproved, logged[1].Status)
+}
+
+// Tests if a changed score is no correction, if the published winner name is unchanged
+func TestOracle_ScoreChangeIsNoCorrection(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	emitted := 0
+	oracle := NewOracle(NewMemoryPublisher(), &OracleConfig{
+		PrimaryAPI:         stub,
+		ApproveCorrections: true,
+		OnCorrection:       func(Correction) { emitted++ },
+	})
+	oracle.RunOnce(context.Background())
+
+	corrected := make([]model.Match, len(past))
+	copy(corrected, past)
+	corrected[len(corrected)-1].Result.Score = "16-14"
+	stub.SetMatches(corrected, future)
+	oracle.RunOnce(context.Background())
+	oracle.RunOnce(context.Background())
+	assert.Equal(t, 0, emitted)
+	assert.Empty(t, oracle.PendingCorrections())
+}
+
+// Tests if the Oracle publishes to alternative Publisher implementations
+func TestOracle_Publishers(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	desired := ConstructDesiredState(past, future, client.GlobalBytes, clock.Real{})
+	publishers := []Publisher{
+		NewMemoryPublisher(),
+		&JSONFilePublisher{Path: t.TempDir() + "/state.json"},
+	}
+	for _, p := range publishers {
+		stub := &StubAPI{}
+		stub.SetMatches(past, future)
+		oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, RefreshInterval: time.Millisecond})
+		oracle.Serve()
+		assert.Eventually(t, func() bool {
+			state, err := p.GetBuffer(context.Background())
+			return err == nil && assert.ObjectsAreEqual(desired, state)
+		}, time.Second, time.Millisecond*5)
+		oracle.Stop()
+	}
+
+	// binary values are rejected unless they are base64-encoded
+	root := map[string]string{"root": "\xff\x00"}
+	text := &JSONFilePublisher{Path: t.TempDir() + "/text.json"}
+	assert.Error(t, text.AchieveDesiredState(context.Background(), root))
+	binary := &JSONFilePublisher{Path: t.TempDir() + "/binary.json", Binary: true}
+	assert.Nil(t, binary.AchieveDesiredState(context.Background(), root))
+	state, err := binary.GetBuffer(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, root, state)
+}
+
+// Tests if DryRun prints the diff without publishing
+func TestOracle_DryRun(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	p := NewMemoryPublisher()
+	assert.Nil(t, p.AchieveDesiredState(context.Background(), map[string]string{"1": "stale"}))
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	var out bytes.Buffer
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, DryRun: true, DryRunOutput: &out})
+	oracle.RunOnce(context.Background())
+
+	state, _ := p.GetBuffer(context.Background())
+	assert.Equal(t, map[string]string{"1": "stale"}, state)
+	assert.Contains(t, out.String(), "- 1 (was \"stale\")")
+	assert.Contains(t, out.String(), fmt.Sprintf("%d to add, 0 to update, 1 to delete", client.GlobalBytes))
+}
+
+// Tests if a dry run does not write to any log, and does not advance the tracked state
+func TestOracle_DryRunSideEffects(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	last := past[len(past)-1]
+	p := NewMemoryPublisher()
+	// a different result of the last match is published
+	assert.Nil(t, p.AchieveDesiredState(context.Background(), map[string]string{strconv.Itoa(last.ID): "Not A Team"}))
+	a, err := archive.Open(filepath.Join(t.TempDir(), "archive.jsonl"))
+	assert.Nil(t, err)
+	defer a.Close()
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	corrections := 0
+	oracle := NewOracle(p, &OracleConfig{
+		PrimaryAPI:   stub,
+		DryRun:       true,
+		DryRunOutput: &bytes.Buffer{},
+		Archive:      a,
+		Finality:     FinalityWindow{Observations: 3},
+		OnCorrection: func(Correction) { corrections++ },
+	})
+	oracle.RunOnce(context.Background())
+	oracle.RunOnce(context.Background())
+
+	assert.Equal(t, 0, a.Len())
+	assert.Equal(t, 0, corrections)
+	assert.Empty(t, oracle.PendingResults())
+	assert.Empty(t, oracle.PendingCorrections())
+	assert.Equal(t, 0, oracle.lastFetched)
+}
+
+// Simulates several days of oracle operation with a manual clock
+func TestOracle_SimulateDays(t *testing.T) {
+	clk := clock.NewManual(time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC))
+	past, future := generator.GetData(clk.Now())
+	future = append(future, generator.GenerateFutureData(future[len(future)-1], 100)...)
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Clock: clk})
+
+	// one match concludes every 2 hours, for 5 days
+	for i := 0; i < 60; i++ {
+		clk.Advance(time.Hour * 2)
+		past, future = generator.ProgressTime(past, future, 1, clk)
+		stub.SetMatches(past, future)
+		oracle.RunOnce(context.Background())
+
+		state, _ := p.GetBuffer(context.Background())
+		assert.Equal(t, ConstructDesiredState(past, future, client.GlobalBytes, clk), state)
+		// no past match older than the TTL is kept while enough future matches exist
+		for _, m := range past {
+			if clk.Now().Sub(m.Date) > PastMatchesTTL && len(future) >= client.GlobalBytes {
+				assert.NotContains(t, state, strconv.Itoa(m.ID))
+			}
+		}
+	}
+}
+
+// Tests if the Oracle publishes compact values that can be decoded again
+func TestOracle_CompactEncoding(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	oracle, b, stub := setupOracleMockedAPI(0)
+	oracle.cfg.Encoding = CompactEncoding{}
+	stub.SetMatches(past, future)
+	oracle.Serve()
+	defer oracle.Stop()
+
+	desired, _ := BuildDesiredState(RecentResultsFirst{}, Format{Encoding: CompactEncoding{}}, past, future, client.GlobalBytes, clock.Real{})
+	assert.True(t, b.ContainsWithin(desired, time.Second, 0))
+
+	last := past[len(past)-1]
+	data, err := b.GetBuffer(context.Background())
+	assert.Nil(t, err)
+	result, err := CompactEncoding{}.DecodeResult(data[strconv.Itoa(last.ID)], last)
+	assert.Nil(t, err)
+	assert.Equal(t, last.Result, result)
+}
+
+// Tests if the compact value of a match stays stable while it is live, and keeps the team
+// IDs and format of the announced match once it has concluded
+func TestOracle_CompleteMatches(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Encoding: CompactEncoding{}, SelectionPolicy: UpcomingFirst{}})
+	announced := future[0]
+	announced.Team1.ID, announced.Team2.ID, announced.Format = 4608, 5995, "bo3"
+	key := strconv.Itoa(announced.ID)
+	value := func() codec.Compact {
+		state, _ := p.GetBuffer(context.Background())
+		c, err := codec.DecodeCompact([]byte(state[key]))
+		assert.Nil(t, err)
+		return c
+	}
+
+	stub.SetMatches(past, append([]model.Match{announced}, future[1:]...))
+	oracle.RunOnce(context.Background())
+	assert.Equal(t, announced.Date.Unix(), value().Time.Unix())
+
+	// live matches are reported with the current time
+	live := announced
+	live.Live = true
+	for i := 0; i < 2; i++ {
+		live.Date = time.Now().Add(time.Minute * time.Duration(i))
+		stub.SetMatches(past, append([]model.Match{live}, future[1:]...))
+		oracle.RunOnce(context.Background())
+		assert.True(t, value().Live())
+		assert.Equal(t, announced.Date.Unix(), value().Time.Unix())
+	}
+
+	// results are reported without team IDs and format
+	concluded := announced
+	concluded.Team1.ID, concluded.Team2.ID, concluded.Format = 0, 0, ""
+	concluded.Result = model.Result{Winner: concluded.Team2.Name, Score: "0-2"}
+	stub.SetMatches(append(past, concluded), future[1:])
+	oracle.RunOnce(context.Background())
+	c := value()
+	assert.True(t, c.Finished())
+	assert.Equal(t, codec.SideTeam2, c.Winner)
+	assert.Equal(t, uint32(4608), c.Team1ID)
+	assert.Equal(t, uint32(5995), c.Team2ID)
+	assert.Equal(t, uint8(3), c.BestOf)
+}
+
+// Tests if a versioned key schema prefixes keys and publishes its metadata
+func TestOracle_KeySchema(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	schema := codec.KeySchema{Version: 1, Source: "hltv", Game: "csgo"}
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, KeySchema: schema, Encoding: CompactEncoding{}})
+	oracle.RunOnce(context.Background())
+
+	state, _ := p.GetBuffer(context.Background())
+	assert.Len(t, state, client.GlobalBytes)
+	assert.Equal(t, "v=1;src=hltv;game=csgo;enc=compact1", state["hltv:csgo:schema"])
+	assert.Contains(t, state, schema.Key(past[len(past)-1].ID))
+}
+
+// Tests if the SafetyGuard refuses to wipe the matches of a versioned key schema, whose
+// metadata key is always part of the desired state
+func TestOracle_KeySchemaRefusesEmptyState(t *testing.T) {
+	clk := clock.NewManual(time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC))
+	past, future := generator.GetData(clk.Now())
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	schema := codec.KeySchema{Version: 1, Source: "hltv", Game: "csgo"}
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, KeySchema: schema, Clock: clk})
+	oracle.RunOnce(context.Background())
+	before, _ := p.GetBuffer(context.Background())
+	assert.Len(t, before, client.GlobalBytes)
+
+	// only a few stale results are left, which do not fill the buffer
+	clk.Advance(PastMatchesTTL * 2)
+	stub.SetMatches(past[:10], []model.Match{})
+	oracle.RunOnce(context.Background())
+	after, _ := p.GetBuffer(context.Background())
+	assert.Equal(t, before, after)
+}
+
+// Tests if results that have left the buffer can still be proven against the history root
+func TestOracle_CommitHistory(t *testing.T) {
+	clk := clock.NewManual(time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC))
+	past, future := generator.GetData(clk.Now())
+	future = append(future, generator.GenerateFutureData(future[len(future)-1], 100)...)
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Clock: clk, CommitHistory: true})
+	// the history cannot be restored after a restart without an Archive
+	oracle.RunOnce(context.Background())
+	state, _ := p.GetBuffer(context.Background())
+	assert.Empty(t, state)
+	oracle.cfg.Archive = tempArchive(t)
+
+	_, err := oracle.Proof(past[0].ID)
+	assert.NotNil(t, err)
+	first := past[len(past)-1]
+	for i := 0; i < 60; i++ {
+		stub.SetMatches(past, future)
+		oracle.RunOnce(context.Background())
+		clk.Advance(time.Hour * 2)
+		past, future = generator.ProgressTime(past, future, 1, clk)
+	}
+
+	state, _ = p.GetBuffer(context.Background())
+	assert.Len(t, state, client.GlobalBytes)
+	assert.NotContains(t, state, strconv.Itoa(first.ID))
+	proof, err := oracle.Proof(first.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, first, proof.Entry.Match)
+	assert.Equal(t, state[codec.RootKey], string(proof.Root))
+	assert.True(t, proof.Proof.Verify([]byte(state[codec.RootKey])))
+	assert.Greater(t, len(oracle.History()), client.GlobalBytes)
+}
+
+// Tests if the history root survives a restart, when it is restored from the Archive
+func TestOracle_ArchiveRestoresHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	clk := clock.NewManual(time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC))
+	past, future := generator.GetData(clk.Now())
+	future = append(future, generator.GenerateFutureData(future[len(future)-1], 100)...)
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	run := func(cycles int) *Oracle {
+		a, err := archive.Open(path)
+		assert.Nil(t, err)
+		defer a.Close()
+		oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Clock: clk, CommitHistory: true, Archive: a})
+		for i := 0; i < cycles; i++ {
+			stub.SetMatches(past, future)
+			oracle.RunOnce(context.Background())
+			clk.Advance(time.Hour * 2)
+			past, future = generator.ProgressTime(past, future, 1, clk)
+		}
+		return oracle
+	}
+	first := past[len(past)-1]
+	run(45)
+	state, _ := p.GetBuffer(context.Background())
+	assert.NotContains(t, state, strconv.Itoa(first.ID))
+	// the restarted oracle keeps committing to results that rotated out before the restart
+	oracle := run(1)
+	state, _ = p.GetBuffer(context.Background())
+	proof, err := oracle.Proof(first.ID)
+	assert.Nil(t, err)
+	assert.True(t, proof.Proof.Verify([]byte(state[codec.RootKey])))
+
+	a, err := archive.Open(path)
+	assert.Nil(t, err)
+	defer a.Close()
+	e, ok := a.Get(first.ID)
+	assert.True(t, ok)
+	assert.Equal(t, first.Result, e.Latest().Result)
+	assert.NotEmpty(t, e.Publications)
+}
+
+// Tests if slots left empty by the fetched matches are filled with archived results
+func TestOracle_FillFromArchive(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	a := tempArchive(t)
+	assert.Nil(t, a.RecordFetch(time.Now(), past...))
+	stub := &StubAPI{}
+	stub.SetMatches(past[len(past)-3:], future[:5])
+	p := NewMemoryPublisher()
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, Archive: a, SelectionPolicy: UpcomingFirst{}})
+
+	oracle.RunOnce(context.Background())
+	state, _ := p.GetBuffer(context.Background())
+	assert.Len(t, state, 8)
+
+	oracle.cfg.FillFromArchive = true
+	oracle.RunOnce(context.Background())
+	state, _ = p.GetBuffer(context.Background())
+	n := len(past) + 5
+	if n > client.GlobalBytes {
+		n = client.GlobalBytes
+	}
+	assert.Len(t, state, n)
+	for _, m := range past[len(past)-(n-5):] {
+		assert.Equal(t, m.Result.Winner, state[strconv.Itoa(m.ID)])
+	}
+}
+
+// Tests if pinned matches that are no longer reported stay on the buffer after a restart
+func TestOracle_RestorePinned(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	last := past[len(past)-1]
+	a := tempArchive(t)
+	p := NewMemoryPublisher()
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	cfg := func() *OracleConfig {
+		return &OracleConfig{PrimaryAPI: stub, Archive: a, Watchlist: NewWatchlist(WatchEntry{ID: last.ID, Retain: time.Hour * 24})}
+	}
+	NewOracle(p, cfg()).RunOnce(context.Background())
+
+	// the match is no longer reported when the oracle restarts
+	stub.SetMatches(past[:len(past)-1], future)
+	NewOracle(p, cfg()).RunOnce(context.Background())
+	state, _ := p.GetBuffer(context.Background())
+	assert.Equal(t, last.Result.Winner, state[strconv.Itoa(last.ID)])
+
+	// without an archive, the match is not restored
+	c := cfg()
+	c.Archive = nil
+	NewOracle(p, c).RunOnce(context.Background())
+	state, _ = p.GetBuffer(context.Background())
+	assert.NotContains(t, state, strconv.Itoa(last.ID))
+}
@@ -1,6 +1,7 @@
 package csgo
 
 import (
+	"github.com/m2q/siam-cs/clock"
 	"github.com/m2q/siam-cs/model"
 	"strconv"
 	"time"
@@ -22,31 +23,31 @@ func CreateWinnerMap(m []model.Match) map[string]string {
 }
 
 // ConstructDesiredState returns the desired state of a buffer of size l that the Oracle wishes
-// to integrate onto the AlgorandBuffer. There are two factors for desirability.
+// to integrate onto the AlgorandBuffer, using the default RecentResultsFirst policy. There are
+// two factors for desirability.
 //
 //  1) Past matches should remain on the buffer until their Date is older than the PastMatchesTTL
 //  2) Remove past matches that are older than their TTL.
 //
-// The desired state also depends on the current time of the system (e.g. because of wanting
+// The desired state also depends on the current time of the clock c (e.g. because of wanting
 // to discard old data).
-func ConstructDesiredState(past []model.Match, future []model.Match, l int) map[string]string {
-	// cut off TTL
-	pastTTL, desired := SplitMatchesAge(past, PastMatchesTTL, time.Now())
-	// append future matches
-	desired = append(desired, future...)
-	// truncate if necessary
-	if len(desired) > l {
-		desired = desired[:l]
-	}
-	// if the length of desired buffer is STILL not maxed, it means that
-	// there are not enough future matches going on. In this case we can
-	// fill the rest with old data.
-	if len(desired) < l {
-		if d := l - len(desired); d <= len(pastTTL) {
-			desired = append(pastTTL[len(pastTTL)-d:], desired...)
-		}
-	}
-	return CreateWinnerMap(desired)
+func ConstructDesiredState(past []model.Match, future []model.Match, l int, c clock.Clock) map[string]string {
+	return ConstructDesiredStateWith(RecentResultsFirst{}, past, future, l, c)
+}
+
+// ConstructDesiredStateWith returns the desired state of a buffer of size l, where the
+// matches occupying the buffer are selected by the given SelectionPolicy. Values that
+// exceed the size limits of the Algorand global state are truncated, see EncodeState.
+func ConstructDesiredStateWith(p SelectionPolicy, past, future []model.Match, l int, c clock.Clock) map[string]string {
+	desired, _ := BuildDesiredState(p, Format{}, past, future, l, c)
+	return desired
+}
+
+// BuildDesiredState is like ConstructDesiredStateWith, but encodes the state in the given
+// Format (see EncodeState) and additionally reports every match that could not be encoded
+// as-is. The state does not contain the metadata of the Format, see Format.Metadata.
+func BuildDesiredState(p SelectionPolicy, f Format, past, future []model.Match, l int, c clock.Clock) (map[string]string, []EncodingIssue) {
+	return EncodeState(p.Select(past, future, l, c.Now()), f)
 }
 
 // ReverseMatches reverses the order of a match array.
@@ -0,0 +1,56 @@
+package csgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProofPath is the path prefix under which the ProofHandler serves inclusion proofs.
+const ProofPath = "/proof/"
+
+// ProofHandler returns an http.Handler that answers GET requests of the form
+// /proof/{matchID} with the HistoryProof of the match, encoded as JSON. The proof is only
+// served once its root has been published, so clients can verify it against the value
+// of codec.RootKey on the buffer. Requests never reach the Publisher: the root is the one
+// last read or written by the serving loop. Requires CommitHistory.
+//
+// Responds with 404 if the result of the match has not been published, and with 503 if
+// the history has not been committed on the buffer (yet).
+func (o *Oracle) ProofHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, ProofPath))
+		if !strings.HasPrefix(r.URL.Path, ProofPath) || err != nil {
+			http.Error(w, "expected "+ProofPath+"{matchID}", http.StatusBadRequest)
+			return
+		}
+		p, err := o.Proof(id)
+		if errors.Is(err, ErrNotPublished) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		// only serve proofs against the root that is currently on chain
+		o.mu.Lock()
+		root := o.root
+		o.mu.Unlock()
+		if !bytes.Equal([]byte(root), p.Root) {
+			http.Error(w, "history root is not published yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			log.Print(err)
+		}
+	})
+}
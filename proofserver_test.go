@@ -0,0 +1,66 @@
+package csgo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/m2q/siam-cs/generator"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingPublisher counts the reads of the published state.
+type countingPublisher struct {
+	*MemoryPublisher
+	reads int
+}
+
+func (p *countingPublisher) GetBuffer(ctx context.Context) (map[string]string, error) {
+	p.reads++
+	return p.MemoryPublisher.GetBuffer(ctx)
+}
+
+func TestProofHandler(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	p := &countingPublisher{MemoryPublisher: NewMemoryPublisher()}
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub, CommitHistory: true, Archive: tempArchive(t), Encoding: CompactEncoding{}})
+	srv := httptest.NewServer(oracle.ProofHandler())
+	defer srv.Close()
+
+	get := func(path string) *http.Response {
+		resp, err := http.Get(srv.URL + path)
+		assert.Nil(t, err)
+		return resp
+	}
+	last := past[len(past)-1]
+	// nothing committed yet
+	assert.Equal(t, http.StatusServiceUnavailable, get(ProofPath+strconv.Itoa(last.ID)).StatusCode)
+
+	oracle.RunOnce(context.Background())
+	reads := p.reads
+	assert.Equal(t, http.StatusBadRequest, get(ProofPath+"abc").StatusCode)
+	assert.Equal(t, http.StatusNotFound, get(ProofPath+strconv.Itoa(future[0].ID)).StatusCode)
+
+	resp := get(ProofPath + strconv.Itoa(last.ID))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var proof HistoryProof
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&proof))
+	assert.Equal(t, last.ID, proof.Entry.Match.ID)
+	assert.Equal(t, last.Result, proof.Entry.Match.Result)
+	assert.Equal(t, proof.Entry.Value, proof.Proof.Value)
+	assert.True(t, proof.Proof.Verify(proof.Root))
+	// requests are answered without reading the published state
+	assert.Equal(t, reads, p.reads)
+
+	// a root that is not on chain is not served
+	assert.Nil(t, p.AchieveDesiredState(context.Background(), map[string]string{}))
+	oracle.setRoot(map[string]string{})
+	assert.Equal(t, http.StatusServiceUnavailable, get(ProofPath+strconv.Itoa(last.ID)).StatusCode)
+}
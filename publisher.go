@@ -0,0 +1,136 @@
+package csgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	siam "github.com/m2q/algo-siam"
+)
+
+// Publisher is a sink for the state computed by the Oracle. The reference implementation
+// is siam.AlgorandBuffer, which publishes to the Algorand blockchain.
+type Publisher interface {
+	// GetBuffer returns the currently published state.
+	GetBuffer(ctx context.Context) (map[string]string, error)
+
+	// AchieveDesiredState turns the published state into the given desired state.
+	AchieveDesiredState(ctx context.Context, desired map[string]string) error
+}
+
+var _ Publisher = (*siam.AlgorandBuffer)(nil)
+
+// MemoryPublisher is a Publisher that keeps the state in memory. It is safe for concurrent
+// use, which makes it suitable for tests and local harnesses.
+type MemoryPublisher struct {
+	mu    sync.Mutex
+	state map[string]string
+}
+
+// NewMemoryPublisher returns an empty MemoryPublisher.
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{state: make(map[string]string)}
+}
+
+// GetBuffer returns a copy of the current state.
+func (p *MemoryPublisher) GetBuffer(ctx context.Context) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return copyState(p.state), nil
+}
+
+// AchieveDesiredState replaces the current state with desired.
+func (p *MemoryPublisher) AchieveDesiredState(ctx context.Context, desired map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = copyState(desired)
+	return nil
+}
+
+// JSONFilePublisher is a Publisher that writes the state to a JSON file, e.g. for staging
+// environments. The file is replaced atomically on every change.
+type JSONFilePublisher struct {
+	Path string
+	// Binary stores values base64-encoded. This is required for binary encodings like
+	// CompactEncoding and for the history root (see OracleConfig.CommitHistory), because
+	// JSON strings cannot hold arbitrary bytes. Without Binary, states with values that
+	// are not valid UTF-8 are rejected.
+	Binary bool
+}
+
+// GetBuffer reads the state from the JSON file. A missing file is an empty state.
+func (p *JSONFilePublisher) GetBuffer(ctx context.Context) (map[string]string, error) {
+	b, err := ioutil.ReadFile(p.Path)
+	if os.IsNotExist(err) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Binary {
+		raw := make(map[string][]byte)
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return nil, err
+		}
+		state := make(map[string]string, len(raw))
+		for k, v := range raw {
+			state[k] = string(v)
+		}
+		return state, nil
+	}
+	state := make(map[string]string)
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// AchieveDesiredState writes desired to the JSON file. Returns an error if a value is not
+// valid UTF-8 and Binary is not set.
+func (p *JSONFilePublisher) AchieveDesiredState(ctx context.Context, desired map[string]string) error {
+	var state interface{} = desired
+	if p.Binary {
+		raw := make(map[string][]byte, len(desired))
+		for k, v := range desired {
+			raw[k] = []byte(v)
+		}
+		state = raw
+	} else {
+		for k, v := range desired {
+			if !utf8.ValidString(v) {
+				return fmt.Errorf("value of key %q is not valid UTF-8, binary values require JSONFilePublisher.Binary", k)
+			}
+		}
+	}
+	b, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(p.Path), filepath.Base(p.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.Path)
+}
+
+// copyState returns a shallow copy of a state.
+func copyState(state map[string]string) map[string]string {
+	c := make(map[string]string, len(state))
+	for k, v := range state {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,86 @@
+package csgo
+
+import "fmt"
+
+// Vote is the opinion of a single verification API on an entry proposed by the PrimaryAPI.
+type Vote struct {
+	// API is the index of the voting API in OracleConfig.VerificationAPIs
+	API int `json:"api"`
+	// Available is false if the API failed or did not respond within MaxVerifyTime
+	Available bool `json:"available"`
+	// Value is the winner reported by the API. Empty if the API does not know the match.
+	Value string `json:"value"`
+	// Known is true if the API reported the match at all
+	Known bool `json:"known"`
+	// Agrees is true if the API reported the same value as the PrimaryAPI
+	Agrees bool `json:"agrees"`
+}
+
+// Quorum decides per match ID whether an entry proposed by the PrimaryAPI is accepted,
+// given the votes of all verification APIs.
+type Quorum interface {
+	// Accept returns true if the votes are sufficient to publish the proposed entry.
+	Accept(votes []Vote) bool
+	// String returns a short description of the quorum, used in reports.
+	String() string
+}
+
+// agreeing returns the number of votes that agree with the proposal.
+func agreeing(votes []Vote) int {
+	n := 0
+	for _, v := range votes {
+		if v.Agrees {
+			n++
+		}
+	}
+	return n
+}
+
+// Unanimous accepts an entry only if every verification API agrees. This is the
+// default Quorum.
+type Unanimous struct{}
+
+func (Unanimous) Accept(votes []Vote) bool { return agreeing(votes) == len(votes) }
+func (Unanimous) String() string           { return "unanimous" }
+
+// Majority accepts an entry if more than half of the verification APIs agree.
+type Majority struct{}
+
+func (Majority) Accept(votes []Vote) bool { return 2*agreeing(votes) > len(votes) }
+func (Majority) String() string           { return "majority" }
+
+// KOfN accepts an entry if at least K verification APIs agree.
+type KOfN struct {
+	K int
+}
+
+func (q KOfN) Accept(votes []Vote) bool { return agreeing(votes) >= q.K }
+func (q KOfN) String() string           { return fmt.Sprintf("%d-of-n", q.K) }
+
+// Weighted accepts an entry if the summed weight of agreeing verification APIs is at least
+// Threshold times the total weight. Weights are indexed like OracleConfig.VerificationAPIs;
+// APIs without an explicit weight have a weight of 1.
+type Weighted struct {
+	Weights   []float64
+	Threshold float64
+}
+
+func (q Weighted) weight(api int) float64 {
+	if api < len(q.Weights) {
+		return q.Weights[api]
+	}
+	return 1
+}
+
+func (q Weighted) Accept(votes []Vote) bool {
+	var total, agreed float64
+	for _, v := range votes {
+		total += q.weight(v.API)
+		if v.Agrees {
+			agreed += q.weight(v.API)
+		}
+	}
+	return agreed >= q.Threshold*total
+}
+
+func (q Weighted) String() string { return fmt.Sprintf("weighted(%.2f)", q.Threshold) }
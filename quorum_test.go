@@ -0,0 +1,36 @@
+package csgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func votes(agree ...bool) []Vote {
+	v := make([]Vote, len(agree))
+	for i, a := range agree {
+		v[i] = Vote{API: i, Available: true, Known: true, Agrees: a}
+	}
+	return v
+}
+
+func TestQuorum_Accept(t *testing.T) {
+	tests := []struct {
+		q      Quorum
+		votes  []Vote
+		accept bool
+	}{
+		{Unanimous{}, votes(true, true, true), true},
+		{Unanimous{}, votes(true, false, true), false},
+		{Unanimous{}, votes(), true},
+		{Majority{}, votes(true, false, true), true},
+		{Majority{}, votes(true, false), false},
+		{KOfN{K: 2}, votes(false, true, true), true},
+		{KOfN{K: 2}, votes(false, false, true), false},
+		{Weighted{Weights: []float64{3, 1, 1}, Threshold: 0.6}, votes(true, false, false), true},
+		{Weighted{Weights: []float64{3, 1, 1}, Threshold: 0.6}, votes(false, true, true), false},
+		{Weighted{Threshold: 0.5}, votes(true, false, false, true), true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.accept, tt.q.Accept(tt.votes), "%s %v", tt.q, tt.votes)
+	}
+}
@@ -0,0 +1,201 @@
+// Package reader decodes the global state of an Algorand application published by the
+// oracle back into typed matches. It detects the schema of the state via the metadata
+// key of its namespace (see codec.MetaKey), and validates every entry.
+package reader
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	siam "github.com/m2q/algo-siam"
+	"github.com/m2q/siam-cs/codec"
+	"github.com/m2q/siam-cs/model"
+)
+
+// Entry is a single decoded match.
+type Entry struct {
+	Key   string
+	Match model.Match
+	// Compact is set if the value is encoded in the compact encoding. It contains the
+	// winner side, which cannot be mapped to a team name, because the compact encoding
+	// only stores team IDs. In this case, Match.Result.Winner is empty.
+	Compact *codec.Compact
+}
+
+// Invalid is an entry that could not be decoded.
+type Invalid struct {
+	Key    string
+	Value  []byte
+	Reason string
+}
+
+// State is the decoded global state of an oracle application.
+type State struct {
+	// Schema is the detected schema. Legacy states have schema version 0.
+	Schema codec.KeySchema
+	// Entries contains all valid entries, ordered by match ID.
+	Entries []Entry
+	// Invalid contains all entries that could not be decoded.
+	Invalid []Invalid
+	// Root is the Merkle root over the history of published results (see codec.RootKey),
+	// or nil if the oracle does not commit its history.
+	Root []byte
+}
+
+// Matches returns the matches of all valid entries, ordered by match ID.
+func (s *State) Matches() []model.Match {
+	matches := make([]model.Match, len(s.Entries))
+	for i, e := range s.Entries {
+		matches[i] = e.Match
+	}
+	return matches
+}
+
+// Decode decodes a raw global state. If the state contains a metadata key, its schema is
+// used. Otherwise, the legacy schema is assumed: bare match IDs as keys and winner names
+// as values. Returns an error if the schema is malformed or uses an unknown encoding, or
+// if the state contains several namespaces, see DecodeNamespace. Entries that cannot be
+// decoded are collected in State.Invalid.
+func Decode(raw map[string][]byte) (*State, error) {
+	schemas, err := Namespaces(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch len(schemas) {
+	case 0:
+		return decode(raw, codec.KeySchema{Encoding: codec.EncodingWinner}, false)
+	case 1:
+		return decode(raw, schemas[0], false)
+	}
+	return nil, fmt.Errorf("state contains %d namespaces, select one with DecodeNamespace", len(schemas))
+}
+
+// DecodeNamespace decodes the entries of a single namespace of a raw global state, e.g.
+// source "hltv" and game "csgo". Keys of other namespaces are ignored. Returns an error if
+// the namespace has no metadata key.
+func DecodeNamespace(raw map[string][]byte, source, game string) (*State, error) {
+	schemas, err := Namespaces(raw)
+	if err != nil {
+		return nil, err
+	}
+	for _, schema := range schemas {
+		if schema.Source == source && schema.Game == game {
+			return decode(raw, schema, true)
+		}
+	}
+	return nil, fmt.Errorf("state contains no schema metadata for namespace %s:%s", source, game)
+}
+
+// Namespaces returns the schemas of all namespaces found in a raw global state, ordered
+// by key. Legacy states have no namespaces. Returns an error if a metadata key is
+// malformed, or does not belong to the namespace it describes.
+func Namespaces(raw map[string][]byte) ([]codec.KeySchema, error) {
+	schemas := make([]codec.KeySchema, 0)
+	for k, v := range raw {
+		if !strings.HasSuffix(k, ":"+codec.MetaKey) {
+			continue
+		}
+		schema, err := codec.ParseMeta(string(v))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		if schema.Reserved(codec.MetaKey) != k {
+			return nil, fmt.Errorf("%s: schema metadata describes namespace %s:%s", k, schema.Source, schema.Game)
+		}
+		schemas = append(schemas, schema)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Reserved(codec.MetaKey) < schemas[j].Reserved(codec.MetaKey)
+	})
+	return schemas, nil
+}
+
+// decode decodes every entry of raw according to schema. If namespaced is set, keys
+// outside the namespace of the schema are ignored instead of reported as invalid.
+func decode(raw map[string][]byte, schema codec.KeySchema, namespaced bool) (*State, error) {
+	if schema.Encoding != codec.EncodingWinner && schema.Encoding != codec.EncodingCompact1 {
+		return nil, fmt.Errorf("unknown encoding %q", schema.Encoding)
+	}
+	s := &State{Schema: schema, Entries: []Entry{}, Invalid: []Invalid{}}
+	for k, v := range raw {
+		if k == schema.Reserved(codec.MetaKey) || namespaced && !strings.HasPrefix(k, schema.Prefix()) {
+			continue
+		}
+		if k == schema.Reserved(codec.RootKey) {
+			s.Root = v
+			continue
+		}
+		e, err := s.decodeEntry(k, v)
+		if err != nil {
+			s.Invalid = append(s.Invalid, Invalid{Key: k, Value: v, Reason: err.Error()})
+			continue
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	sort.Slice(s.Entries, func(i, j int) bool { return s.Entries[i].Match.ID < s.Entries[j].Match.ID })
+	sort.Slice(s.Invalid, func(i, j int) bool { return s.Invalid[i].Key < s.Invalid[j].Key })
+	return s, nil
+}
+
+// decodeEntry decodes a single key-value pair according to the schema of the state.
+func (s *State) decodeEntry(k string, v []byte) (Entry, error) {
+	id, err := s.Schema.ParseKey(k)
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{Key: k, Match: model.Match{ID: id}}
+	switch s.Schema.Encoding {
+	case codec.EncodingWinner:
+		if !utf8.Valid(v) {
+			return Entry{}, fmt.Errorf("winner name is not valid UTF-8")
+		}
+		e.Match.Result.Winner = string(v)
+	case codec.EncodingCompact1:
+		c, err := codec.DecodeCompact(v)
+		if err != nil {
+			return Entry{}, err
+		}
+		e.Compact = &c
+		e.Match.Team1.ID = int(c.Team1ID)
+		e.Match.Team2.ID = int(c.Team2ID)
+		e.Match.Date = c.Time
+		e.Match.Live = c.Live()
+		e.Match.Result.Score = c.Score()
+		if c.BestOf > 0 {
+			e.Match.Format = fmt.Sprintf("bo%d", c.BestOf)
+		}
+	}
+	return e, nil
+}
+
+// FromApplication extracts the raw global state of an Algorand application, as returned
+// by an Algorand node (or client.AlgorandMock).
+func FromApplication(app models.Application) (map[string][]byte, error) {
+	raw := make(map[string][]byte, len(app.Params.GlobalState))
+	for _, kv := range app.Params.GlobalState {
+		k, err := base64.StdEncoding.DecodeString(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := base64.StdEncoding.DecodeString(kv.Value.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		raw[string(k)] = v
+	}
+	return raw, nil
+}
+
+// Read reads and decodes the global state of the application managed by b.
+func Read(ctx context.Context, b *siam.AlgorandBuffer) (*State, error) {
+	raw, err := b.GetBufferRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(raw)
+}
@@ -0,0 +1,113 @@
+package reader
+
+import (
+	"context"
+	"github.com/m2q/algo-siam/client"
+	"github.com/m2q/siam-cs"
+	"github.com/m2q/siam-cs/codec"
+	"github.com/m2q/siam-cs/generator"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+
+	siam "github.com/m2q/algo-siam"
+)
+
+func raw(state map[string]string) map[string][]byte {
+	r := make(map[string][]byte, len(state))
+	for k, v := range state {
+		r[k] = []byte(v)
+	}
+	return r
+}
+
+func TestDecode_Legacy(t *testing.T) {
+	s, err := Decode(raw(map[string]string{"2": "G2", "1": "", "bogus": "x"}))
+	assert.Nil(t, err)
+	assert.Equal(t, codec.LegacyVersion, s.Schema.Version)
+	assert.Len(t, s.Entries, 2)
+	assert.Equal(t, 1, s.Entries[0].Match.ID)
+	assert.Equal(t, "G2", s.Entries[1].Match.Result.Winner)
+	assert.Len(t, s.Invalid, 1)
+	assert.Equal(t, "bogus", s.Invalid[0].Key)
+}
+
+func TestDecode_Compact(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	f := csgo.Format{
+		Keys:     codec.KeySchema{Version: 1, Source: "hltv", Game: "csgo"},
+		Encoding: csgo.CompactEncoding{},
+	}
+	state, _ := csgo.EncodeState(append(past[len(past)-5:], future[:5]...), f)
+	for k, v := range f.Metadata() {
+		state[k] = v
+	}
+	state["hltv:csgo:1"] = "truncated"
+	state[f.Keys.Reserved(codec.RootKey)] = "root"
+
+	s, err := Decode(raw(state))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, s.Schema.Version)
+	assert.Equal(t, codec.EncodingCompact1, s.Schema.Encoding)
+	assert.Len(t, s.Entries, 10)
+	assert.Len(t, s.Invalid, 1)
+	assert.Equal(t, []byte("root"), s.Root)
+
+	last := past[len(past)-1]
+	for _, e := range s.Entries {
+		if e.Match.ID == last.ID {
+			assert.True(t, e.Compact.Finished())
+			assert.Equal(t, last.Result.Score, e.Match.Result.Score)
+			assert.Equal(t, last.Date.Unix(), e.Match.Date.Unix())
+		}
+	}
+}
+
+func TestDecode_UnknownEncoding(t *testing.T) {
+	_, err := Decode(raw(map[string]string{"hltv:csgo:schema": "v=2;src=hltv;game=csgo;enc=zstd"}))
+	assert.Error(t, err)
+}
+
+func TestDecode_Namespaces(t *testing.T) {
+	state := raw(map[string]string{
+		"hltv:csgo:schema": "v=1;src=hltv;game=csgo;enc=winner",
+		"hltv:csgo:root":   "root",
+		"hltv:csgo:1":      "G2",
+		"hltv:dota:schema": "v=1;src=hltv;game=dota;enc=winner",
+		"hltv:dota:root":   "other root",
+		"hltv:dota:1":      "OG",
+	})
+	schemas, err := Namespaces(state)
+	assert.Nil(t, err)
+	assert.Len(t, schemas, 2)
+	_, err = Decode(state)
+	assert.Error(t, err)
+
+	s, err := DecodeNamespace(state, "hltv", "dota")
+	assert.Nil(t, err)
+	assert.Len(t, s.Entries, 1)
+	assert.Empty(t, s.Invalid)
+	assert.Equal(t, "OG", s.Entries[0].Match.Result.Winner)
+	assert.Equal(t, []byte("other root"), s.Root)
+
+	_, err = DecodeNamespace(state, "hltv", "valorant")
+	assert.Error(t, err)
+	// metadata must describe the namespace of its key
+	_, err = Namespaces(raw(map[string]string{"hltv:dota:schema": "v=1;src=hltv;game=csgo;enc=winner"}))
+	assert.Error(t, err)
+}
+
+func TestRead_Mock(t *testing.T) {
+	c := client.CreateAlgorandClientMock("", "")
+	b, err := siam.NewAlgorandBuffer(c, client.GeneratePrivateKey64())
+	assert.Nil(t, err)
+	assert.Nil(t, b.PutElements(context.Background(), map[string]string{"42": "OG"}))
+
+	s, err := Read(context.Background(), b)
+	assert.Nil(t, err)
+	assert.Equal(t, "OG", s.Entries[0].Match.Result.Winner)
+
+	r, err := FromApplication(c.App)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("OG"), r["42"])
+}
@@ -0,0 +1,195 @@
+package csgo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/m2q/siam-cs/codec"
+	"github.com/m2q/siam-cs/model"
+)
+
+// ReconciledKey is a single key found on the buffer during reconciliation.
+type ReconciledKey struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	// MatchID is the match ID contained in the key, or zero if the key is malformed
+	MatchID int `json:"match_id,omitempty"`
+	// Reason explains why the key is unknown or malformed
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReconciliationReport describes the contents of the buffer before the Oracle writes to it
+// for the first time, see Reconcile.
+type ReconciliationReport struct {
+	Time time.Time `json:"time"`
+	// Known contains the keys of matches known from the Archive or the PrimaryAPI
+	Known []ReconciledKey `json:"known"`
+	// Unknown contains well-formed keys of matches that are neither archived nor fetched
+	Unknown []ReconciledKey `json:"unknown"`
+	// Malformed contains keys and values that do not belong to the Format of the Oracle
+	Malformed []ReconciledKey `json:"malformed"`
+}
+
+// Foreign returns all keys that were not written by this Oracle, i.e. unknown and
+// malformed keys.
+func (r *ReconciliationReport) Foreign() []ReconciledKey {
+	return append(append([]ReconciledKey{}, r.Unknown...), r.Malformed...)
+}
+
+func (r *ReconciliationReport) String() string {
+	return fmt.Sprintf("reconciled buffer: %d known, %d unknown, %d malformed keys",
+		len(r.Known), len(r.Unknown), len(r.Malformed))
+}
+
+// ForeignDataError is returned if the buffer contains foreign data at startup and
+// RefuseForeignData is set.
+type ForeignDataError struct {
+	Report *ReconciliationReport
+}
+
+func (e *ForeignDataError) Error() string {
+	keys := make([]string, 0)
+	for _, k := range e.Report.Foreign() {
+		keys = append(keys, k.Key)
+	}
+	return fmt.Sprintf("refusing to start: buffer contains foreign keys %s", strings.Join(keys, ", "))
+}
+
+// Reconcile reads the current contents of the buffer and matches every key against the
+// matches known from the Archive. If the Archive is not configured or does not know every
+// match, the PrimaryAPI is fetched as well. Keys that belong to neither, or whose values
+// cannot be decoded in the Format of the Oracle, are reported as foreign. Pinned matches
+// that are no longer reported are only known from the Archive, since they are removed
+// from the buffer after a restart without one, see Watchlist.
+func (o *Oracle) Reconcile(ctx context.Context) (*ReconciliationReport, error) {
+	r, _, err := o.reconcileBuffer(ctx)
+	return r, err
+}
+
+// reconcileBuffer implements Reconcile. If the PrimaryAPI was fetched, the fetch is
+// returned as well, so it can be reused by the first cycle.
+func (o *Oracle) reconcileBuffer(ctx context.Context) (*ReconciliationReport, *fetchResult, error) {
+	current, err := o.publisher.GetBuffer(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := &ReconciliationReport{Time: o.clock().Now(), Known: []ReconciledKey{}, Unknown: []ReconciledKey{}, Malformed: []ReconciledKey{}}
+	keys := make([]string, 0, len(current))
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var fetched *fetchResult
+	var index map[string]model.Match
+	lookup := func(k string, id int) (model.Match, bool, error) {
+		if o.cfg.Archive != nil {
+			if e, ok := o.cfg.Archive.Get(id); ok {
+				return e.Latest(), true, nil
+			}
+		}
+		if index == nil {
+			past, future, diag, err := o.fetch(ctx)
+			if err != nil {
+				return model.Match{}, false, err
+			}
+			fetched = &fetchResult{past: past, future: future, diag: diag}
+			index = IndexMatches(o.format(), past, future)
+		}
+		m, ok := index[k]
+		return m, ok, nil
+	}
+	for _, k := range keys {
+		rk := ReconciledKey{Key: k, Value: []byte(current[k])}
+		if reason, reserved := o.checkReserved(k, current[k]); reserved {
+			if reason == "" {
+				continue
+			}
+			rk.Reason = reason
+			r.Malformed = append(r.Malformed, rk)
+			continue
+		}
+		rk.MatchID, err = o.format().Keys.ParseKey(k)
+		if err != nil {
+			rk.Reason = err.Error()
+			r.Malformed = append(r.Malformed, rk)
+			continue
+		}
+		m, ok, err := lookup(k, rk.MatchID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			rk.Reason = "match is neither archived nor fetched"
+			r.Unknown = append(r.Unknown, rk)
+			continue
+		}
+		if err := o.checkValue(current[k], m); err != nil {
+			rk.Reason = err.Error()
+			r.Malformed = append(r.Malformed, rk)
+			continue
+		}
+		r.Known = append(r.Known, rk)
+	}
+	return r, fetched, nil
+}
+
+// checkReserved returns true if k is a reserved key, and a reason if it is not expected
+// with the configuration of the Oracle.
+func (o *Oracle) checkReserved(k, v string) (reason string, reserved bool) {
+	switch k {
+	case o.format().Keys.Reserved(codec.MetaKey):
+		if meta, ok := o.format().Metadata()[k]; !ok || meta != v {
+			return fmt.Sprintf("schema metadata %q does not match the configured schema", v), true
+		}
+		return "", true
+	case o.rootKey():
+		if !o.cfg.CommitHistory {
+			return "history root found, but CommitHistory is disabled", true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// checkValue returns an error if v is not a value of match m in the Format of the Oracle.
+func (o *Oracle) checkValue(v string, m model.Match) error {
+	if v == encodedValue(m, o.format()) {
+		return nil
+	}
+	r, err := o.format().encoding().DecodeResult(v, m)
+	if err != nil {
+		return err
+	}
+	// winner names may be truncated, see FitValue
+	if r.Winner != "" && !strings.HasPrefix(m.Team1.Name, r.Winner) && !strings.HasPrefix(m.Team2.Name, r.Winner) {
+		return fmt.Errorf("winner %q did not play in match %d", r.Winner, m.ID)
+	}
+	return nil
+}
+
+// reconcile runs Reconcile, logs the report and passes it to the OnReconciliation hook.
+// Returns a ForeignDataError if foreign data is present and RefuseForeignData is set. If
+// the PrimaryAPI was fetched, the fetch is kept for the next call of fetch.
+func (o *Oracle) reconcile(ctx context.Context) error {
+	r, fetched, err := o.reconcileBuffer(ctx)
+	if err != nil {
+		return err
+	}
+	o.prefetched = fetched
+	log.Print(r)
+	for _, k := range r.Foreign() {
+		log.Printf("foreign key %q: %s", k.Key, k.Reason)
+	}
+	if o.cfg.OnReconciliation != nil {
+		o.cfg.OnReconciliation(r)
+	}
+	if o.cfg.RefuseForeignData && len(r.Foreign()) > 0 {
+		return &ForeignDataError{Report: r}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package csgo
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/m2q/siam-cs/generator"
+	"github.com/m2q/siam-cs/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOracle_Reconcile(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	last := past[len(past)-1]
+	p := NewMemoryPublisher()
+	state := map[string]string{
+		strconv.Itoa(last.ID):      last.Result.Winner,
+		strconv.Itoa(future[0].ID): "Not A Team",
+		"99999999":                 "",
+		"foo":                      "bar",
+		"hltv:csgo:schema":         "v=1;src=hltv;game=csgo;enc=winner",
+	}
+	assert.Nil(t, p.AchieveDesiredState(context.Background(), state))
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: stub})
+
+	r, err := oracle.Reconcile(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, r.Known, 1)
+	assert.Equal(t, last.ID, r.Known[0].MatchID)
+	assert.Len(t, r.Unknown, 1)
+	assert.Equal(t, 99999999, r.Unknown[0].MatchID)
+	assert.Len(t, r.Malformed, 3)
+	assert.Len(t, r.Foreign(), 4)
+}
+
+func TestOracle_RefuseForeignData(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	p := NewMemoryPublisher()
+	assert.Nil(t, p.AchieveDesiredState(context.Background(), map[string]string{"foo": "bar"}))
+	stub := &StubAPI{}
+	stub.SetMatches(past, future)
+	var report *ReconciliationReport
+	oracle := NewOracle(p, &OracleConfig{
+		PrimaryAPI:        stub,
+		RefuseForeignData: true,
+		OnReconciliation:  func(r *ReconciliationReport) { report = r },
+	})
+	oracle.Serve()
+	oracle.Wait()
+
+	assert.IsType(t, &ForeignDataError{}, oracle.Err())
+	assert.Len(t, report.Malformed, 1)
+	state, _ := p.GetBuffer(context.Background())
+	assert.Equal(t, map[string]string{"foo": "bar"}, state)
+}
+
+// countingAPI is an API that counts how often it was fetched.
+type countingAPI struct {
+	StubAPI
+	fetches int
+}
+
+func (c *countingAPI) Fetch(ctx context.Context) (past, future []model.Match, err error) {
+	c.fetches++
+	return c.StubAPI.Fetch(ctx)
+}
+
+// Tests if the first cycle reuses the fetch made during reconciliation
+func TestOracle_ReconcileReusesFetch(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	p := NewMemoryPublisher()
+	assert.Nil(t, p.AchieveDesiredState(context.Background(), map[string]string{strconv.Itoa(future[0].ID): ""}))
+	api := &countingAPI{}
+	api.SetMatches(past, future)
+	oracle := NewOracle(p, &OracleConfig{PrimaryAPI: api})
+
+	assert.Nil(t, oracle.reconcile(context.Background()))
+	oracle.RunOnce(context.Background())
+	assert.Equal(t, 1, api.fetches)
+	state, _ := p.GetBuffer(context.Background())
+	assert.Contains(t, state, strconv.Itoa(past[len(past)-1].ID))
+
+	oracle.RunOnce(context.Background())
+	assert.Equal(t, 2, api.fetches)
+}
+
+// Tests if pinned matches that are no longer reported are only known from the Archive
+func TestOracle_ReconcileWatchlist(t *testing.T) {
+	past, future := generator.GetData(time.Now())
+	last := past[len(past)-1]
+	p := NewMemoryPublisher()
+	assert.Nil(t, p.AchieveDesiredState(context.Background(), map[string]string{strconv.Itoa(last.ID): last.Result.Winner}))
+	stub := &StubAPI{}
+	stub.SetMatches(past[:len(past)-1], future)
+	oracle := NewOracle(p, &OracleConfig{
+		PrimaryAPI: stub,
+		Watchlist:  NewWatchlist(WatchEntry{ID: last.ID, Retain: time.Hour * 24}),
+	})
+
+	r, err := oracle.Reconcile(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, r.Unknown, 1)
+
+	oracle.cfg.Archive = tempArchive(t)
+	assert.Nil(t, oracle.cfg.Archive.RecordFetch(time.Now(), last))
+	r, err = oracle.Reconcile(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, r.Known, 1)
+	assert.Empty(t, r.Foreign())
+}
@@ -0,0 +1,174 @@
+package csgo
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// SelectionPolicy decides which matches occupy the slots of the buffer. Different
+// deployments can use the limited number of slots for what their consumers care about.
+type SelectionPolicy interface {
+	// Select returns at most l matches out of past and future matches, at time now.
+	// Both past and future are in chronological order. Implementations must not modify
+	// the given slices.
+	Select(past, future []model.Match, l int, now time.Time) []model.Match
+}
+
+// RecentResultsFirst is the default SelectionPolicy. Past matches are kept until they are
+// older than PastMatchesTTL, then future matches are appended. If there are not enough
+// matches to fill the buffer, it is backfilled with older results.
+type RecentResultsFirst struct{}
+
+func (RecentResultsFirst) Select(past, future []model.Match, l int, now time.Time) []model.Match {
+	// cut off TTL
+	pastTTL, recent := SplitMatchesAge(past, PastMatchesTTL, now)
+	// append future matches
+	desired := make([]model.Match, 0, len(recent)+len(future))
+	desired = append(desired, recent...)
+	desired = append(desired, future...)
+	// truncate if necessary
+	if len(desired) > l {
+		desired = desired[:l]
+	}
+	// if the length of desired buffer is STILL not maxed, it means that
+	// there are not enough future matches going on. In this case we can
+	// fill the rest with old data.
+	if len(desired) < l {
+		if d := l - len(desired); d <= len(pastTTL) {
+			desired = append(append([]model.Match{}, pastTTL[len(pastTTL)-d:]...), desired...)
+		}
+	}
+	return desired
+}
+
+// UpcomingFirst fills the buffer with live and upcoming matches first, earliest first.
+// Remaining slots are filled with the most recent results.
+type UpcomingFirst struct{}
+
+func (UpcomingFirst) Select(past, future []model.Match, l int, now time.Time) []model.Match {
+	desired := make([]model.Match, 0, l)
+	for i := 0; i < len(future) && len(desired) < l; i++ {
+		desired = append(desired, future[i])
+	}
+	for i := len(past) - 1; i >= 0 && len(desired) < l; i-- {
+		desired = append(desired, past[i])
+	}
+	return desired
+}
+
+// EventPriority reserves slots for matches of the given events (by name). Results of
+// prioritized events are kept for PastMatchesTTL. Remaining slots are filled by the
+// Fallback policy, which defaults to RecentResultsFirst.
+type EventPriority struct {
+	Events   []string
+	Fallback SelectionPolicy
+}
+
+func (p EventPriority) Select(past, future []model.Match, l int, now time.Time) []model.Match {
+	events := make(map[string]bool, len(p.Events))
+	for _, e := range p.Events {
+		events[e] = true
+	}
+	_, recent := SplitMatchesAge(past, PastMatchesTTL, now)
+	prioritized := make([]model.Match, 0)
+	for _, m := range recent {
+		if events[m.Event.Name] {
+			prioritized = append(prioritized, m)
+		}
+	}
+	for _, m := range future {
+		if events[m.Event.Name] {
+			prioritized = append(prioritized, m)
+		}
+	}
+	return selectPrioritized(prioritized, p.Fallback, past, future, l, now)
+}
+
+// HybridScoring scores every match and selects the l matches with the highest score.
+// The score of a match is the weight of its kind (result, live or upcoming), decaying
+// by half every HalfLife of distance between the match Date and now, plus the weight of
+// its event. Ties are broken in favor of more recent matches.
+type HybridScoring struct {
+	ResultWeight   float64
+	LiveWeight     float64
+	UpcomingWeight float64
+	// EventWeights are added to the score of matches of the event (by name)
+	EventWeights map[string]float64
+	// HalfLife of the score. Zero disables the decay.
+	HalfLife time.Duration
+}
+
+// score returns the score of a match at time now.
+func (p HybridScoring) score(m model.Match, past bool, now time.Time) float64 {
+	weight := p.UpcomingWeight
+	if past {
+		weight = p.ResultWeight
+	} else if m.Live {
+		weight = p.LiveWeight
+	}
+	if p.HalfLife > 0 && !m.Live {
+		distance := math.Abs(float64(now.Sub(m.Date)))
+		weight *= math.Pow(0.5, distance/float64(p.HalfLife))
+	}
+	return weight + p.EventWeights[m.Event.Name]
+}
+
+func (p HybridScoring) Select(past, future []model.Match, l int, now time.Time) []model.Match {
+	type scored struct {
+		match model.Match
+		score float64
+	}
+	candidates := make([]scored, 0, len(past)+len(future))
+	for _, m := range past {
+		candidates = append(candidates, scored{m, p.score(m, true, now)})
+	}
+	for _, m := range future {
+		candidates = append(candidates, scored{m, p.score(m, false, now)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].match.Date.After(candidates[j].match.Date)
+	})
+	if len(candidates) > l {
+		candidates = candidates[:l]
+	}
+	desired := make([]model.Match, len(candidates))
+	for i, c := range candidates {
+		desired[i] = c.match
+	}
+	return desired
+}
+
+// selectPrioritized selects up to l prioritized matches, and fills the remaining slots
+// with matches selected by the fallback policy (RecentResultsFirst if nil).
+func selectPrioritized(prioritized []model.Match, fallback SelectionPolicy, past, future []model.Match, l int, now time.Time) []model.Match {
+	if fallback == nil {
+		fallback = RecentResultsFirst{}
+	}
+	if len(prioritized) > l {
+		prioritized = prioritized[:l]
+	}
+	chosen := make(map[int]bool, len(prioritized))
+	for _, m := range prioritized {
+		chosen[m.ID] = true
+	}
+	desired := append([]model.Match{}, prioritized...)
+	rest := fallback.Select(without(past, chosen), without(future, chosen), l-len(desired), now)
+	return append(desired, rest...)
+}
+
+// without returns the matches whose ID is not contained in ids.
+func without(m []model.Match, ids map[int]bool) []model.Match {
+	result := make([]model.Match, 0, len(m))
+	for _, v := range m {
+		if !ids[v.ID] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
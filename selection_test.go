@@ -0,0 +1,112 @@
+package csgo
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/m2q/siam-cs/model"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// selectionData returns 4 past matches (1 hour apart, the last one at now) and 4 future
+// matches (1 hour apart). Matches with even IDs belong to "Major".
+func selectionData(now time.Time) ([]model.Match, []model.Match) {
+	past := make([]model.Match, 4)
+	future := make([]model.Match, 4)
+	for i := range past {
+		past[i] = model.Match{ID: i + 1, Date: now.Add(time.Duration(i-3) * time.Hour), Result: model.Result{Winner: "A"}}
+		future[i] = model.Match{ID: i + 5, Date: now.Add(time.Duration(i+1) * time.Hour)}
+	}
+	for _, m := range [][]model.Match{past, future} {
+		for i := range m {
+			if m[i].ID%2 == 0 {
+				m[i].Event.Name = "Major"
+			}
+		}
+	}
+	return past, future
+}
+
+func ids(m []model.Match) []int {
+	result := make([]int, len(m))
+	for i, v := range m {
+		result[i] = v.ID
+	}
+	return result
+}
+
+func TestSelectionPolicies(t *testing.T) {
+	now := time.Now()
+	past, future := selectionData(now)
+	tests := []struct {
+		name   string
+		policy SelectionPolicy
+		want   []int
+	}{
+		{"recent results", RecentResultsFirst{}, []int{1, 2, 3, 4}},
+		{"upcoming", UpcomingFirst{}, []int{5, 6, 7, 8}},
+		{"event", EventPriority{Events: []string{"Major"}}, []int{2, 4, 6, 8}},
+		{"watchlist", WatchlistPinned{Watchlist: NewWatchlist(WatchEntry{ID: 7}, WatchEntry{ID: 1, Retain: time.Hour}),
+			Fallback: UpcomingFirst{}}, []int{7, 5, 6, 8}},
+		{"watchlist retain", WatchlistPinned{Watchlist: NewWatchlist(WatchEntry{ID: 1, Retain: time.Hour * 3}),
+			Fallback: UpcomingFirst{}}, []int{1, 5, 6, 7}},
+		{"hybrid", HybridScoring{ResultWeight: 1, UpcomingWeight: 2, HalfLife: time.Hour,
+			EventWeights: map[string]float64{"Major": 0.5}}, []int{4, 6, 5, 2}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ids(tt.policy.Select(past, future, 4, now)), tt.name)
+	}
+}
+
+// Tests if a watchlist loaded from file keeps pinned matches that are no longer reported
+func TestWatchlist_LoadAndRetain(t *testing.T) {
+	path := t.TempDir() + "/watchlist.json"
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`[{"id": 2, "retain": "24h"}]`), 0644))
+	w, err := LoadWatchlist(context.Background(), path)
+	assert.Nil(t, err)
+	assert.Equal(t, []WatchEntry{{ID: 2, Retain: time.Hour * 24}}, w.Entries())
+
+	now := time.Now()
+	past, future := selectionData(now)
+	policy := WatchlistPinned{Watchlist: w, Fallback: UpcomingFirst{}}
+	assert.Equal(t, []int{2, 5, 6, 7}, ids(policy.Select(past, future, 4, now)))
+	// match 2 is no longer reported, but stays pinned until its retention ends
+	assert.Equal(t, []int{2, 5, 6, 7}, ids(policy.Select(past[2:], future, 4, now)))
+	assert.Equal(t, []int{5, 6, 7, 8}, ids(policy.Select(past[2:], future, 4, now.Add(time.Hour*24))))
+}
+
+// Tests if a pinned match without a result is unpinned once it is no longer reported
+func TestWatchlist_Expiry(t *testing.T) {
+	w := NewWatchlist(WatchEntry{ID: 5, Retain: time.Hour})
+	w.Expiry = time.Hour * 6
+	now := time.Now()
+	past, future := selectionData(now)
+	policy := WatchlistPinned{Watchlist: w, Fallback: RecentResultsFirst{}}
+	assert.Equal(t, []int{5, 1, 2, 3}, ids(policy.Select(past, future, 4, now)))
+	// match 5 drops off the matches page, but never shows up in the results
+	assert.Equal(t, []int{5, 1, 2, 3}, ids(policy.Select(past, future[1:], 4, now.Add(time.Hour*6))))
+	assert.Equal(t, []int{1, 2, 3, 4}, ids(policy.Select(past, future[1:], 4, now.Add(time.Hour*7))))
+}
+
+// Tests if a watchlist that was not created by NewWatchlist is usable
+func TestWatchlist_ZeroValue(t *testing.T) {
+	path := t.TempDir() + "/watchlist.json"
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`[{"id": 2, "retain": "24h"}]`), 0644))
+	w := &Watchlist{Source: path}
+	assert.Nil(t, w.Refresh(context.Background()))
+	now := time.Now()
+	past, future := selectionData(now)
+	policy := WatchlistPinned{Watchlist: &Watchlist{}, Fallback: UpcomingFirst{}}
+	assert.Equal(t, []int{5, 6, 7, 8}, ids(policy.Select(past, future, 4, now)))
+	policy.Watchlist = w
+	assert.Equal(t, []int{2, 5, 6, 7}, ids(policy.Select(past, future, 4, now)))
+	assert.NotNil(t, w.clone())
+}
+
+func TestWatchEntry_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal([]WatchEntry{{ID: 1}, {ID: 2, Retain: time.Hour}})
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"id":1},{"id":2,"retain":"1h0m0s"}]`, string(b))
+}
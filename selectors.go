@@ -0,0 +1,168 @@
+package csgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// SelectorsVersion is the version of the selector configuration format understood by this
+// build. Configurations of any other version are rejected by LoadSelectors.
+const SelectorsVersion = 1
+
+// Selectors contains the CSS selectors and attribute names used to scrape HLTV. They are
+// compiled into the oracle (see DefaultSelectors), but can be overridden by a JSON config
+// file, so a change of the HLTV markup can be fixed without a new release.
+type Selectors struct {
+	Version int              `json:"version"`
+	Results ResultsSelectors `json:"results"`
+	Matches MatchesSelectors `json:"matches"`
+	Details DetailsSelectors `json:"details"`
+}
+
+// ResultsSelectors are used to scrape the results page.
+type ResultsSelectors struct {
+	// Row selects a single result
+	Row string `json:"row"`
+	// Result selects the result inside a row. Its parent is the link to the match.
+	Result string `json:"result"`
+	// TimeAttr is the attribute of the row that contains the unix time in milliseconds
+	TimeAttr  string `json:"time_attr"`
+	Event     string `json:"event"`
+	Team1     string `json:"team1"`
+	Team2     string `json:"team2"`
+	TeamName  string `json:"team_name"`
+	Winner    string `json:"winner"`
+	ScoreWon  string `json:"score_won"`
+	ScoreLost string `json:"score_lost"`
+}
+
+// MatchesSelectors are used to scrape the page of live and upcoming matches.
+type MatchesSelectors struct {
+	// Live and Upcoming select a single live or upcoming match
+	Live     string `json:"live"`
+	Upcoming string `json:"upcoming"`
+	// Link selects the link to the match, which contains the match ID
+	Link string `json:"link"`
+	Time string `json:"time"`
+	// TimeAttr is the attribute of Time that contains the unix time in milliseconds
+	TimeAttr  string `json:"time_attr"`
+	Event     string `json:"event"`
+	EventLogo string `json:"event_logo"`
+	Format    string `json:"format"`
+	// TeamName selects the names of both teams, in order
+	TeamName string `json:"team_name"`
+	// Team1Attr and Team2Attr are the attributes of a match that contain the team IDs
+	Team1Attr string `json:"team1_attr"`
+	Team2Attr string `json:"team2_attr"`
+}
+
+// DetailsSelectors are used to scrape the page of a single match, see DetailFetcher.
+type DetailsSelectors struct {
+	// Map selects a single map of the match
+	Map     string `json:"map"`
+	MapName string `json:"map_name"`
+	// Team1Rounds and Team2Rounds select the number of rounds won by each team on a map
+	Team1Rounds string `json:"team1_rounds"`
+	Team2Rounds string `json:"team2_rounds"`
+	// Veto selects the steps of the map veto, e.g. "3. Astralis picked Inferno"
+	Veto string `json:"veto"`
+}
+
+// DefaultSelectors returns the selectors compiled into this build.
+func DefaultSelectors() *Selectors {
+	return &Selectors{
+		Version: SelectorsVersion,
+		Results: ResultsSelectors{
+			Row:       ".result-con",
+			Result:    ".result",
+			TimeAttr:  "data-zonedgrouping-entry-unix",
+			Event:     ".event-name",
+			Team1:     ".team1",
+			Team2:     ".team2",
+			TeamName:  ".team",
+			Winner:    ".team-won",
+			ScoreWon:  ".score-won",
+			ScoreLost: ".score-lost",
+		},
+		Matches: MatchesSelectors{
+			Live:      ".liveMatch",
+			Upcoming:  ".upcomingMatch",
+			Link:      "a.match",
+			Time:      ".matchTime",
+			TimeAttr:  "data-unix",
+			Event:     ".matchEventName",
+			EventLogo: "img.matchEventLogo",
+			Format:    ".matchMeta",
+			TeamName:  ".matchTeamName",
+			Team1Attr: "team1",
+			Team2Attr: "team2",
+		},
+		Details: DetailsSelectors{
+			Map:         ".mapholder",
+			MapName:     ".mapname",
+			Team1Rounds: ".results-left .results-team-score",
+			Team2Rounds: ".results-right .results-team-score",
+			Veto:        ".veto-box .padding > div",
+		},
+	}
+}
+
+// LoadSelectors reads a selector configuration from a JSON file. Fields missing from the
+// file keep their default value. Returns an error if the version is not supported, or a
+// selector is invalid.
+func LoadSelectors(path string) (*Selectors, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := DefaultSelectors()
+	s.Version = 0
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Validate returns an error if the version is not supported, or a selector is empty or
+// cannot be compiled.
+func (s *Selectors) Validate() error {
+	if s.Version != SelectorsVersion {
+		return fmt.Errorf("unsupported selectors version %d, expected %d", s.Version, SelectorsVersion)
+	}
+	for _, group := range []interface{}{s.Results, s.Matches, s.Details} {
+		v := reflect.ValueOf(group)
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Tag.Get("json")
+			value := v.Field(i).String()
+			if value == "" {
+				return fmt.Errorf("selector %s is empty", name)
+			}
+			if _, err := cascadia.Compile(value); err != nil && !isAttr(name) {
+				return fmt.Errorf("selector %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isAttr returns true if the config field with the given name is an attribute name,
+// rather than a selector.
+func isAttr(name string) bool {
+	return strings.HasSuffix(name, "_attr")
+}
+
+// selectors returns the configured Selectors, or DefaultSelectors if none are set.
+func (h *HLTV) selectors() *Selectors {
+	if h.Selectors == nil {
+		return DefaultSelectors()
+	}
+	return h.Selectors
+}
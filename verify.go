@@ -0,0 +1,197 @@
+package csgo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// verification is the outcome of fetching a single verification API.
+type verification struct {
+	index   int
+	winners map[string]string
+	err     error
+}
+
+// CreateWinnerView merges past and future matches into a single state, encoded like the
+// desired state (see EncodeState). This is the view of an API that gets compared against
+// the PrimaryAPI's proposal.
+func CreateWinnerView(past, future []model.Match, f Format) map[string]string {
+	view, _ := EncodeState(past, f)
+	encoded, _ := EncodeState(future, f)
+	for k, v := range encoded {
+		view[k] = v
+	}
+	return view
+}
+
+// fetchVerifications fetches all given APIs concurrently and returns their winner views,
+// indexed like apis. If an API fails, or does not respond within timeout, its view is nil.
+// A timeout of zero waits until every API has responded or ctx is cancelled. If complete
+// is set, it is applied to the matches of every API before they are encoded.
+func fetchVerifications(ctx context.Context, apis []API, f Format, timeout time.Duration, complete func(past, future []model.Match) ([]model.Match, []model.Match)) []map[string]string {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	views := make([]map[string]string, len(apis))
+	ch := make(chan verification, len(apis))
+	for i, api := range apis {
+		go func(i int, api API) {
+			past, future, err := api.Fetch(ctx)
+			if err != nil {
+				ch <- verification{index: i, err: err}
+				return
+			}
+			if complete != nil {
+				past, future = complete(past, future)
+			}
+			ch <- verification{index: i, winners: CreateWinnerView(past, future, f)}
+		}(i, api)
+	}
+	// don't rely on the APIs to honor ctx, an API that ignores it must not stall the caller
+	for range apis {
+		select {
+		case v := <-ch:
+			if v.err != nil {
+				log.Printf("verification API %d failed: %v", v.index, v.err)
+				continue
+			}
+			views[v.index] = v.winners
+		case <-ctx.Done():
+			if timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+				log.Printf("verification APIs did not respond within %v", timeout)
+			}
+			return views
+		}
+	}
+	return views
+}
+
+// Decision records why a single entry proposed by the PrimaryAPI was or was not published.
+type Decision struct {
+	// Key is the buffer key, i.e. the match ID
+	Key string `json:"key"`
+	// Proposed is the value proposed by the PrimaryAPI
+	Proposed string `json:"proposed"`
+	// Votes contains one vote per verification API
+	Votes []Vote `json:"votes"`
+	// Accepted is true if the Quorum accepted the proposal
+	Accepted bool `json:"accepted"`
+}
+
+// VerificationReport is the structured outcome of a verification round. It can be used
+// to audit why a given match was or was not written to the buffer.
+type VerificationReport struct {
+	Time      time.Time  `json:"time"`
+	Quorum    string     `json:"quorum"`
+	Decisions []Decision `json:"decisions"`
+	// Discarded is true if the whole proposal was discarded, because the Quorum could
+	// not be reached with the verification APIs that were available.
+	Discarded bool `json:"discarded"`
+}
+
+// Rejected returns the decisions that were not accepted.
+func (r *VerificationReport) Rejected() []Decision {
+	rejected := make([]Decision, 0)
+	for _, d := range r.Decisions {
+		if !d.Accepted {
+			rejected = append(rejected, d)
+		}
+	}
+	return rejected
+}
+
+// quorum returns the configured Quorum, or Unanimous if none is set.
+func (o *Oracle) quorum() Quorum {
+	if o.cfg.Quorum == nil {
+		return Unanimous{}
+	}
+	return o.cfg.Quorum
+}
+
+// verify cross-checks the desired state proposed by the PrimaryAPI against every
+// VerificationAPI. Whether an entry is accepted is decided by the configured Quorum.
+// Rejected entries are held back: if they are already on the buffer, their current value
+// is kept, otherwise they are not published at all. If the Quorum cannot be reached with
+// the verification APIs that are available, the whole proposal is discarded.
+func (o *Oracle) verify(ctx context.Context, current, desired map[string]string) (map[string]string, error) {
+	views := fetchVerifications(ctx, o.cfg.VerificationAPIs, o.format(), o.cfg.MaxVerifyTime, o.completeMatches)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	q := o.quorum()
+	report := &VerificationReport{Time: o.clock().Now(), Quorum: q.String()}
+	defer o.publishReport(report)
+
+	// check if the quorum is reachable at all, assuming every available API agrees
+	if !q.Accept(castVotes(views, "", "", true)) {
+		report.Discarded = true
+		return nil, fmt.Errorf("quorum %s unreachable with available verification APIs, discarding proposal", q)
+	}
+	keys := make([]string, 0, len(desired))
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	verified := make(map[string]string, len(desired))
+	for _, k := range keys {
+		v := desired[k]
+		d := Decision{Key: k, Proposed: v, Votes: castVotes(views, k, v, false)}
+		d.Accepted = q.Accept(d.Votes)
+		report.Decisions = append(report.Decisions, d)
+		if d.Accepted {
+			verified[k] = v
+			continue
+		}
+		log.Printf("holding back match %s: quorum %s rejected proposal %q", k, q, v)
+		if old, ok := current[k]; ok {
+			verified[k] = old
+		}
+	}
+	return verified, nil
+}
+
+// castVotes collects the votes of all views on the proposal (k, v). Unavailable views
+// (nil) never agree. If hypothetical is set, every available view agrees.
+func castVotes(views []map[string]string, k, v string, hypothetical bool) []Vote {
+	votes := make([]Vote, len(views))
+	for i, view := range views {
+		votes[i] = Vote{API: i, Available: view != nil}
+		if view == nil {
+			continue
+		}
+		if hypothetical {
+			votes[i].Agrees = true
+			continue
+		}
+		votes[i].Value, votes[i].Known = view[k]
+		votes[i].Agrees = votes[i].Known && votes[i].Value == v
+	}
+	return votes
+}
+
+// publishReport stores the report, so it can be retrieved via LastVerificationReport,
+// and passes it to the OnVerification hook if configured.
+func (o *Oracle) publishReport(r *VerificationReport) {
+	o.mu.Lock()
+	o.report = r
+	o.mu.Unlock()
+	if o.cfg.OnVerification != nil {
+		o.cfg.OnVerification(r)
+	}
+}
+
+// LastVerificationReport returns the report of the most recent verification round, or nil
+// if no verification has taken place yet.
+func (o *Oracle) LastVerificationReport() *VerificationReport {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.report
+}
@@ -0,0 +1,275 @@
+package csgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m2q/siam-cs/model"
+)
+
+// DefaultPinExpiry is the duration a pinned match without a result stays pinned after it
+// was last reported, if Watchlist.Expiry is not set.
+const DefaultPinExpiry = time.Hour * 24
+
+// WatchEntry pins a single match on the buffer.
+type WatchEntry struct {
+	// ID is the match ID
+	ID int
+	// Retain is the duration a concluded match stays pinned, measured from its Date.
+	// In JSON, it is given as a duration string, e.g. "48h".
+	Retain time.Duration
+}
+
+// watchEntryJSON is the JSON representation of a WatchEntry
+type watchEntryJSON struct {
+	ID     int    `json:"id"`
+	Retain string `json:"retain,omitempty"`
+}
+
+func (e WatchEntry) MarshalJSON() ([]byte, error) {
+	raw := watchEntryJSON{ID: e.ID}
+	if e.Retain != 0 {
+		raw.Retain = e.Retain.String()
+	}
+	return json.Marshal(raw)
+}
+
+func (e *WatchEntry) UnmarshalJSON(b []byte) error {
+	var raw watchEntryJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	e.ID, e.Retain = raw.ID, 0
+	if raw.Retain != "" {
+		d, err := time.ParseDuration(raw.Retain)
+		if err != nil {
+			return err
+		}
+		e.Retain = d
+	}
+	return nil
+}
+
+// Watchlist is a set of matches that are pinned on the buffer: from the moment they are
+// announced, until Retain after they have concluded. Pinned matches occupy reserved slots,
+// which are never evicted by truncation or PastMatchesTTL. Pinned matches stay on the
+// buffer even if the API stops reporting them. The pinned matches are only kept in memory,
+// so after a restart they are restored from the Archive of the Oracle, if configured.
+// Without an Archive, pinned matches that are no longer reported are removed from the
+// buffer after a restart. Watchlist is safe for concurrent use, and the zero value is an
+// empty watchlist.
+//
+// Only matches reported by the API can be pinned, i.e. matches that are not listed on the
+// scraped pages (e.g. because of their star rating) never enter the buffer. If a pinned
+// match is no longer reported before its result is known, e.g. because it dropped off the
+// matches page but never appeared on the results page, it is unpinned after Expiry.
+type Watchlist struct {
+	// Source is an optional file path or http(s) URL of a JSON array of WatchEntry, which
+	// is reloaded by Refresh.
+	Source string
+	// Expiry is the duration a pinned match without a result stays pinned after it was
+	// last reported. Defaults to DefaultPinExpiry.
+	Expiry time.Duration
+
+	mu      sync.Mutex
+	entries map[int]WatchEntry
+	// seen contains the last reported version of each pinned match, and reported the
+	// time it was last reported
+	seen     map[int]model.Match
+	reported map[int]time.Time
+}
+
+// NewWatchlist returns a Watchlist with the given entries.
+func NewWatchlist(entries ...WatchEntry) *Watchlist {
+	w := &Watchlist{}
+	w.Replace(entries)
+	return w
+}
+
+// init lazily initializes the pinned matches. Must be called with the mutex held.
+func (w *Watchlist) init() {
+	if w.seen == nil {
+		w.seen = make(map[int]model.Match)
+		w.reported = make(map[int]time.Time)
+	}
+}
+
+// LoadWatchlist returns a Watchlist, loaded from a file path or http(s) URL.
+func LoadWatchlist(ctx context.Context, source string) (*Watchlist, error) {
+	w := NewWatchlist()
+	w.Source = source
+	return w, w.Refresh(ctx)
+}
+
+// Replace replaces all entries of the watchlist.
+func (w *Watchlist) Replace(entries []WatchEntry) {
+	m := make(map[int]WatchEntry, len(entries))
+	for _, e := range entries {
+		m[e.ID] = e
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.init()
+	w.entries = m
+	for id := range w.seen {
+		if _, ok := m[id]; !ok {
+			delete(w.seen, id)
+			delete(w.reported, id)
+		}
+	}
+}
+
+// clone returns a copy of the watchlist.
+func (w *Watchlist) clone() *Watchlist {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.init()
+	c := &Watchlist{Source: w.Source, Expiry: w.Expiry, entries: w.entries,
+		seen: make(map[int]model.Match, len(w.seen)), reported: make(map[int]time.Time, len(w.reported))}
+	for id, m := range w.seen {
+		c.seen[id] = m
+		c.reported[id] = w.reported[id]
+	}
+	return c
+}
+
+// Entries returns all entries of the watchlist, ordered by ID.
+func (w *Watchlist) Entries() []WatchEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entries := make([]WatchEntry, 0, len(w.entries))
+	for _, e := range w.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// Refresh reloads the entries from Source. If loading fails, the current entries are kept.
+func (w *Watchlist) Refresh(ctx context.Context) error {
+	if w.Source == "" {
+		return errors.New("watchlist has no source")
+	}
+	var b []byte
+	var err error
+	if strings.HasPrefix(w.Source, "http://") || strings.HasPrefix(w.Source, "https://") {
+		b, err = fetchWatchlist(ctx, w.Source)
+	} else {
+		b, err = ioutil.ReadFile(w.Source)
+	}
+	if err != nil {
+		return err
+	}
+	entries := make([]WatchEntry, 0)
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+	w.Replace(entries)
+	return nil
+}
+
+// fetchWatchlist performs a GET request to url and returns the response body.
+func fetchWatchlist(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := (&http.Client{Timeout: DefaultRequestTimeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, errors.New(res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// pinned returns the pinned matches at time now: every pinned match that is upcoming or
+// live, and every concluded pinned match whose Date is at most Retain ago. Matches that
+// are no longer reported by past and future are taken from previous calls, unless they
+// have no result and were last reported more than Expiry ago. The result is ordered by
+// Date, most recent first.
+func (w *Watchlist) pinned(past, future []model.Match, now time.Time) []model.Match {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.init()
+	for _, matches := range [][]model.Match{past, future} {
+		for _, m := range matches {
+			if _, ok := w.entries[m.ID]; ok {
+				w.seen[m.ID] = m
+				w.reported[m.ID] = now
+			}
+		}
+	}
+	pinned := make([]model.Match, 0, len(w.seen))
+	for id, m := range w.seen {
+		concluded := m.Result.Winner != ""
+		if concluded && now.Sub(m.Date) > w.entries[id].Retain {
+			continue
+		}
+		if !concluded && now.Sub(w.reported[id]) > w.expiry() {
+			log.Printf("unpinning match %d: not reported since %v, and no result is known", id, w.reported[id])
+			delete(w.seen, id)
+			delete(w.reported, id)
+			continue
+		}
+		pinned = append(pinned, m)
+	}
+	sort.Slice(pinned, func(i, j int) bool {
+		if !pinned[i].Date.Equal(pinned[j].Date) {
+			return pinned[i].Date.After(pinned[j].Date)
+		}
+		return pinned[i].ID < pinned[j].ID
+	})
+	return pinned
+}
+
+// restore pins m as if it had been reported at time now, e.g. after a restart. Matches
+// that are not on the watchlist or have already been reported are ignored.
+func (w *Watchlist) restore(m model.Match, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.init()
+	if _, ok := w.entries[m.ID]; !ok {
+		return
+	}
+	if _, ok := w.seen[m.ID]; !ok {
+		w.seen[m.ID] = m
+		w.reported[m.ID] = now
+	}
+}
+
+// expiry returns the configured Expiry, or DefaultPinExpiry if none is set.
+func (w *Watchlist) expiry() time.Duration {
+	if w.Expiry == 0 {
+		return DefaultPinExpiry
+	}
+	return w.Expiry
+}
+
+// WatchlistPinned reserves slots for the pinned matches of a Watchlist. If there are more
+// pinned matches than slots, a warning is logged and the most recent pinned matches are
+// kept. Remaining slots are filled by the Fallback policy, which defaults to
+// RecentResultsFirst.
+type WatchlistPinned struct {
+	Watchlist *Watchlist
+	Fallback  SelectionPolicy
+}
+
+func (p WatchlistPinned) Select(past, future []model.Match, l int, now time.Time) []model.Match {
+	pinned := p.Watchlist.pinned(past, future, now)
+	if len(pinned) > l {
+		log.Printf("WARNING: %d pinned matches exceed the %d available slots, dropping %d",
+			len(pinned), l, len(pinned)-l)
+	}
+	return selectPrioritized(pinned, p.Fallback, past, future, l, now)
+}